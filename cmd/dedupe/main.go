@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/factories"
+	"github.com/egnd/fb2lib/internal/repos"
+)
+
+var (
+	appVersion = "debug"
+
+	showVersion = flag.Bool("version", false, "Show app version.")
+	apply       = flag.Bool("apply", false, "Mark found duplicates at the index, only report is printed otherwise.")
+	cfgPath     = flag.String("config", "configs/app.yml", "Configuration file path.")
+	cfgPrefix   = flag.String("env-prefix", "FBL", "Prefix for env variables.")
+)
+
+func main() {
+	startTS := time.Now()
+
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(appVersion)
+		return
+	}
+
+	cfg := factories.NewViperCfg(*cfgPath, *cfgPrefix)
+	logger := factories.NewZerolog(cfg, os.Stderr)
+	rules := entities.NewDedupeRules("dedupe", cfg)
+
+	ctx, stop := factories.NewShutdownContext(logger)
+	defer stop()
+
+	repoBooks := repos.NewBooksLevelBleve(0,
+		map[repos.BucketType]*leveldb.DB{
+			repos.BucketBooks:   factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "books"),
+			repos.BucketAuthReg: factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "authors_reg"),
+			repos.BucketAliases: factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "aliases"),
+		},
+		factories.NewBleveIndex(cfg.GetString("adapters.bleve.dir"), "books", entities.NewBookIndexMapping()),
+		jsoniter.ConfigCompatibleWithStandardLibrary.Marshal,
+		jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal,
+		logger,
+	).SetAuthorsAliases(entities.NewAuthorAliases("authors.aliases", cfg))
+	defer repoBooks.Close()
+
+	var candidates []entities.DupCandidate
+
+	if err := repoBooks.IterateOver(ctx, func(book *entities.Book) error {
+		candidates = append(candidates, entities.NewDupCandidate(book, repoBooks.ResolveAuthorKey))
+		return nil
+	}); err != nil {
+		logger.Error().Err(err).Msg("iterating over books")
+		return
+	}
+
+	clusters := entities.FindDuplicates(candidates, rules)
+
+	var cntDups int
+	for _, cluster := range clusters {
+		cntDups += len(cluster.Dups)
+		PrintCluster(os.Stdout, cluster)
+	}
+
+	logger.Info().Int("books", len(candidates)).Int("clusters", len(clusters)).Int("dups", cntDups).
+		Dur("dur", time.Since(startTS)).Msg("duplicates search finished")
+
+	if !*apply {
+		return
+	}
+
+	changed, err := GetChangedBooks(candidates, clusters, repoBooks)
+	if err != nil {
+		logger.Error().Err(err).Msg("get changed books")
+		return
+	}
+
+	if err = repoBooks.UpdateBooks(changed); err != nil {
+		logger.Error().Err(err).Msg("mark duplicates")
+		return
+	}
+
+	logger.Info().Int("changed", len(changed)).Msg("duplicates marked")
+}
+
+func PrintCluster(out io.Writer, cluster entities.DupCluster) {
+	fmt.Fprintf(out, "%s [%s]\n", cluster.Main.Title, strings.Join(cluster.Main.Authors, ", "))
+
+	for k, item := range append([]entities.DupCandidate{cluster.Main}, cluster.Dups...) {
+		mark := "dup "
+		if k == 0 {
+			mark = "main"
+		}
+
+		fmt.Fprintf(out, "  %s %s [%s] %s (%d bytes, %d)\n", mark, item.ID, item.Lib, item.Src, item.Size, item.Year)
+	}
+}
+
+// GetChangedBooks returns books which duplicates markers should be updated, stale markers are cleared.
+func GetChangedBooks(
+	candidates []entities.DupCandidate, clusters []entities.DupCluster, repo *repos.BooksLevelBleve,
+) ([]*entities.Book, error) {
+	type state struct {
+		dupOf string
+		alts  []string
+	}
+
+	states := make(map[string]state, len(clusters)*2)
+	for _, cluster := range clusters {
+		states[cluster.Main.ID] = state{alts: cluster.IDs()}
+
+		for _, item := range cluster.Dups {
+			states[item.ID] = state{dupOf: cluster.Main.ID}
+		}
+	}
+
+	var res []*entities.Book
+
+	for _, item := range candidates {
+		target := states[item.ID]
+
+		if target.dupOf == item.DupOf && fmt.Sprint(target.alts) == fmt.Sprint(item.Alternates) {
+			continue
+		}
+
+		book, err := repo.GetByID(item.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		book.DupOf, book.Alternates = target.dupOf, target.alts
+		res = append(res, book)
+	}
+
+	return res, nil
+}
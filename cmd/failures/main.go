@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/factories"
+	"github.com/egnd/fb2lib/internal/repos"
+)
+
+var (
+	appVersion = "debug"
+
+	showVersion = flag.Bool("version", false, "Show app version.")
+	libName     = flag.String("lib", "", "Show failures of the library only.")
+	stage       = flag.String("stage", "", "Show failures of the stage only (define,read,parse,skip).")
+	query       = flag.String("q", "", "Show failures with the text at source path or error.")
+	cfgPath     = flag.String("config", "configs/app.yml", "Configuration file path.")
+	cfgPrefix   = flag.String("env-prefix", "FBL", "Prefix for env variables.")
+)
+
+func main() {
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(appVersion)
+		return
+	}
+
+	cfg := factories.NewViperCfg(*cfgPath, *cfgPrefix)
+	logger := factories.NewZerolog(cfg, os.Stderr)
+
+	repoFailures := repos.NewIndexFailures(factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "failures"),
+		jsoniter.ConfigCompatibleWithStandardLibrary.Marshal,
+		jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal,
+	)
+	defer repoFailures.Close()
+
+	failures, err := repoFailures.Find(entities.IndexFailuresFilter{Lib: *libName, Stage: *stage, Query: *query}, nil)
+	if err != nil {
+		logger.Error().Err(err).Msg("find failures")
+		return
+	}
+
+	for _, item := range failures {
+		PrintFailure(os.Stdout, item)
+	}
+
+	logger.Info().Int("cnt", len(failures)).Msg("failures found")
+}
+
+func PrintFailure(out io.Writer, item entities.IndexFailure) {
+	fmt.Fprintf(out, "%s %-6s [%s] %s\n  %s\n", item.TS.Format(time.RFC3339), item.Stage, item.Lib, item.Src, item.Error)
+}
@@ -1,18 +1,15 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
-	"io"
-	"io/fs"
 	"os"
-	"runtime"
-	"sync"
+	"path"
 	"time"
 
-	"github.com/egnd/go-pipeline"
-	"github.com/egnd/go-pipeline/pools"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/profile"
 	"github.com/rs/zerolog"
@@ -24,8 +21,9 @@ import (
 
 	"github.com/egnd/fb2lib/internal/entities"
 	"github.com/egnd/fb2lib/internal/factories"
+	"github.com/egnd/fb2lib/internal/indexer"
 	"github.com/egnd/fb2lib/internal/repos"
-	"github.com/egnd/fb2lib/internal/tasks"
+	"github.com/egnd/fb2lib/pkg/metrics"
 )
 
 var (
@@ -36,16 +34,17 @@ var (
 	cfgPath     = flag.String("config", "configs/app.yml", "Configuration file path.")
 	cfgPrefix   = flag.String("env-prefix", "FBL", "Prefix for env variables.")
 	profiler    = flag.String("pprof", "", "Enable profiler (mem,allocs,heap,cpu,trace,goroutine,mutex,block,thread).")
+	retry       = flag.Bool("retry", false, "Re-process only items failed at previous runs.")
+	watch       = flag.Bool("watch", false, "Keep indexing new, changed and removed library items after indexing.")
+	report      = flag.String("report", "", "Write JSON report of the run to the file (- for stdout).")
 )
 
 func main() {
 	var (
-		wg         sync.WaitGroup
-		bars       *mpb.Progress
-		barTotal   *mpb.Bar
-		cntTotal   entities.CntAtomic32
-		cntIndexed entities.CntAtomic32
-		startTS    = time.Now()
+		idx      *indexer.Indexer
+		bars     *mpb.Progress
+		barTotal *mpb.Bar
+		startTS  = time.Now()
 	)
 
 	flag.Parse()
@@ -55,13 +54,20 @@ func main() {
 		return
 	}
 
-	cfg := InitConfig(*cfgPath, *cfgPrefix)
+	cfg := factories.NewViperCfg(*cfgPath, *cfgPrefix)
 	logger := factories.NewZerolog(cfg, os.Stderr)
 
+	ctx, stop := factories.NewShutdownContext(logger)
+	defer stop()
+
 	defer func() {
+		if idx == nil {
+			return
+		}
+
 		logger.Info().
-			Uint32("succeed", cntIndexed.Total()).
-			Uint32("failed", cntTotal.Total()-cntIndexed.Total()).
+			Uint32("succeed", idx.Succeed()).
+			Uint32("failed", idx.Failed()).
 			Dur("dur", time.Since(startTS)).Msg("indexing finished")
 	}()
 
@@ -74,131 +80,175 @@ func main() {
 		panic(err)
 	}
 
+	repoFailures := repos.NewIndexFailures(factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "failures"),
+		jsoniter.ConfigCompatibleWithStandardLibrary.Marshal,
+		jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal,
+	)
+	defer repoFailures.Close()
+
+	var failures []entities.IndexFailure
+	if *retry {
+		if failures, err = repoFailures.Find(entities.IndexFailuresFilter{}, nil); err != nil {
+			panic(err)
+		}
+	}
+
 	if !*hideBar {
 		bars = mpb.New(mpb.WithOutput(os.Stdout))
-		barTotal = GetProgressBar(bars, libs, cfg, &logger)
+
+		if *retry {
+			barTotal = GetProgressBar(bars, GetFailuresSize(failures, libs), cfg, &logger)
+		} else {
+			barTotal = GetProgressBar(bars, libs.GetSize(), cfg, &logger)
+		}
+	}
+
+	// aliases resolve authors of indexed works
+	buckets := map[repos.BucketType]*leveldb.DB{
+		repos.BucketBooks:   factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "books"),
+		repos.BucketDocs:    factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "docs"),
+		repos.BucketAliases: factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "aliases"),
+	}
+
+	if *watch {
+		// summary is rebuilt after changes
+		for _, bucket := range repos.SummaryBuckets {
+			buckets[bucket] = factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), string(bucket))
+		}
 	}
 
-	repoBooks := repos.NewBooksLevelBleve(cfg.GetInt("indexer.batch_size"),
-		map[repos.BucketType]*leveldb.DB{
-			repos.BucketBooks: factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "books"),
-		},
+	// marks are closed after books, hooks of the last books batch save checkpoints on close
+	repoMarks := repos.NewLibMarks(factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "marks"))
+	defer repoMarks.Close()
+
+	repoBooks := repos.NewBooksLevelBleve(cfg.GetInt("indexer.batch_size"), buckets,
 		factories.NewBleveIndex(cfg.GetString("adapters.bleve.dir"), "books", entities.NewBookIndexMapping()),
 		jsoniter.ConfigCompatibleWithStandardLibrary.Marshal,
 		jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal,
 		logger,
-	)
+	).SetAuthorsAliases(entities.NewAuthorAliases("authors.aliases", cfg))
 	defer repoBooks.Close()
 
-	repoMarks := repos.NewLibMarks(factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "marks"))
-	defer repoMarks.Close()
+	repoRuns := repos.NewIndexRuns(factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "runs"),
+		jsoniter.ConfigCompatibleWithStandardLibrary.Marshal,
+		jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal,
+	)
+	defer repoRuns.Close()
 
-	rules, err := entities.NewIndexRules("indexer.rules", cfg)
+	idx, err = indexer.NewIndexer(cfg, libs, repoBooks, repoMarks, repoFailures, repoRuns, bars, barTotal, logger)
 	if err != nil {
 		panic(err)
 	}
+	defer idx.Close()
 
-	pipe := pools.NewSemaphore(cfg.GetInt("indexer.threads_cnt"), &wg, func(next pipeline.TaskExecutor) pipeline.TaskExecutor {
-		return func(task pipeline.Task) error {
-			logger.Debug().Str("task", task.ID()).Msg("iterate")
-
-			if err := next(task); err != nil {
-				var indexed *tasks.ErrAlreadyIndexed
-				if errors.As(err, &indexed) {
-					logger.Info().Str("task", task.ID()).Msg(err.Error())
-				} else {
-					logger.Error().Str("task", task.ID()).Err(err).Msg("iterate")
-				}
-			}
+	if textfile := cfg.GetString("metrics.textfile"); textfile != "" {
+		factories.RegisterStoreMetrics(cfg, repoBooks)
+		defer RunMetricsTextfile(textfile, cfg.GetDuration("metrics.interval"), logger)()
+	}
 
-			return nil
-		}
-	})
-	defer pipe.Close()
+	if *retry {
+		err = idx.Retry(ctx, failures)
+	} else {
+		err = idx.Run(ctx, "")
+	}
 
-	readingPool := pools.NewBusPool(cfg.GetInt("indexer.read_threads"), cfg.GetInt("indexer.read_buff"), &wg,
-		func(next pipeline.TaskExecutor) pipeline.TaskExecutor {
-			return func(task pipeline.Task) error {
-				logger.Debug().Str("task", task.ID()).Msg("read")
+	if *report != "" {
+		if rerr := WriteReport(*report, idx.Report()); rerr != nil {
+			logger.Error().Err(rerr).Str("file", *report).Msg("write report")
+		}
+	}
 
-				if err := next(task); err != nil {
-					logger.Error().Str("task", task.ID()).Err(err).Msg("read")
-				}
+	switch {
+	case errors.Is(err, indexer.ErrCanceled):
+		logger.Warn().Msg("indexing interrupted, pushed books are saved")
+		return
+	case errors.Is(err, indexer.ErrGraceTimeout):
+		logger.Error().Err(err).Msg("indexing interrupted, pending books are saved")
+		return
+	case err != nil:
+		panic(err)
+	case *retry:
+		return
+	}
 
-				return nil
-			}
-		},
-	)
-	defer readingPool.Close()
-
-	parsingPool := pools.NewBusPool(cfg.GetInt("indexer.parse_threads"), cfg.GetInt("indexer.parse_buff"), &wg,
-		func(next pipeline.TaskExecutor) pipeline.TaskExecutor {
-			return func(task pipeline.Task) error {
-				logger.Debug().Str("task", task.ID()).Msg("parse")
-
-				if err := next(task); err != nil {
-					var skipRule *tasks.ErrSkipRule
-					if errors.As(err, &skipRule) {
-						logger.Warn().Str("task", task.ID()).Msg(err.Error())
-					} else {
-						logger.Error().Str("task", task.ID()).Err(err).Msg("parse")
-					}
-				} else {
-					cntIndexed.Inc(1)
-				}
-
-				return nil
-			}
-		},
-	)
-	defer parsingPool.Close()
+	if *watch {
+		Watch(ctx, cfg, libs, idx, repoBooks, repoMarks, repoFailures, logger)
+	}
+}
 
-	libItems, err := libs.GetItems()
+// Watch indexes changes of libraries until the process is interrupted.
+func Watch(ctx context.Context, cfg *viper.Viper, libs entities.Libraries, idx *indexer.Indexer,
+	repoBooks *repos.BooksLevelBleve, repoMarks *repos.LibMarks, repoFailures *repos.IndexFailures,
+	logger zerolog.Logger,
+) {
+	watcher, err := indexer.NewWatcher(cfg, libs, idx, repoBooks, repoMarks, repoFailures, logger)
 	if err != nil {
 		panic(err)
 	}
 
-	var num int
-	total := len(libItems)
-	for _, v := range libItems {
-		num++
-		lib := libs[v.Lib]
-		itemPath := v.Item
-		readerTaskFactory := func(reader io.ReadCloser, book entities.Book) error {
-			cntTotal.Inc(1)
-			return readingPool.Push(tasks.NewReadTask(book.Src, book.Lib, reader, func(data io.Reader) error {
-				return parsingPool.Push(tasks.NewParseFB2Task(data, book, rules, lib.Encoder, repoBooks, barTotal))
-			}))
-		}
-		pipe.Push(tasks.NewDefineItemTask(itemPath, lib, repoMarks, barTotal, readerTaskFactory, func(finfo fs.FileInfo) error {
-			return tasks.NewReadZipTask(num, total, itemPath, finfo, lib, bars, readerTaskFactory).Do()
-		}))
+	watcher.Refresh(ctx)
+
+	if err = watcher.Run(ctx); err != nil {
+		logger.Error().Err(err).Msg("watch libraries")
 	}
 
-	wg.Wait()
+	if err = watcher.Close(); err != nil {
+		logger.Error().Err(err).Msg("stop watching")
+	}
 }
 
-func InitConfig(path, prefix string) *viper.Viper {
-	cfg := factories.NewViperCfg(*cfgPath, *cfgPrefix)
+// WriteReport writes JSON report of the run to the file or to stdout.
+func WriteReport(filePath string, run *entities.IndexRun) error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
 
-	if cfg.GetInt("indexer.threads_cnt") == 0 {
-		cfg.Set("indexer.threads_cnt", 1)
+	if filePath == "-" {
+		_, err = fmt.Println(string(data))
+		return err
 	}
 
-	if cfg.GetInt("indexer.read_threads") == 0 {
-		cfg.Set("indexer.read_threads", cfg.GetInt("indexer.threads_cnt")*10)
+	return os.WriteFile(filePath, append(data, '\n'), 0644)
+}
+
+// RunMetricsTextfile writes metrics to the file for the node exporter textfile collector periodically, returned func
+// writes them for the last time and stops writing.
+func RunMetricsTextfile(filePath string, interval time.Duration, logger zerolog.Logger) func() {
+	if interval == 0 {
+		interval = 15 * time.Second
 	}
 
-	if cfg.GetInt("indexer.parse_threads") == 0 {
-		cnt := runtime.NumCPU() / 2
-		if cnt == 0 {
-			cnt = 1
+	write := func() {
+		if err := metrics.Default.WriteFile(filePath); err != nil {
+			logger.Error().Err(err).Str("file", filePath).Msg("write metrics")
 		}
-
-		cfg.Set("indexer.parse_threads", cnt)
 	}
 
-	return cfg
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				write()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		write()
+	}
 }
 
 func RunProfiler(profType string, cfg *viper.Viper) interface{ Stop() } {
@@ -235,7 +285,7 @@ func RunProfiler(profType string, cfg *viper.Viper) interface{ Stop() } {
 	return profile.Start(pprofopts...)
 }
 
-func GetProgressBar(bars *mpb.Progress, libs entities.Libraries, cfg *viper.Viper, logger *zerolog.Logger) *mpb.Bar {
+func GetProgressBar(bars *mpb.Progress, total int64, cfg *viper.Viper, logger *zerolog.Logger) *mpb.Bar {
 	if err := os.MkdirAll(cfg.GetString("logs.dir"), 0644); err != nil {
 		panic(err)
 	}
@@ -249,7 +299,7 @@ func GetProgressBar(bars *mpb.Progress, libs entities.Libraries, cfg *viper.Vipe
 
 	*logger = logger.Output(zerolog.ConsoleWriter{Out: logOutput, NoColor: true})
 
-	return bars.AddBar(libs.GetSize(),
+	return bars.AddBar(total,
 		mpb.PrependDecorators(
 			decor.Name("indexing: "),
 			decor.NewPercentage("%d"),
@@ -262,3 +312,21 @@ func GetProgressBar(bars *mpb.Progress, libs entities.Libraries, cfg *viper.Vipe
 		),
 	)
 }
+
+// GetFailuresSize returns size of failed items to retry.
+func GetFailuresSize(failures []entities.IndexFailure, libs entities.Libraries) (res int64) {
+	for _, item := range failures {
+		switch {
+		case item.Locator.SizeCompressed > 0:
+			res += int64(item.Locator.SizeCompressed)
+		case item.Locator.Size > 0:
+			res += int64(item.Locator.Size)
+		default:
+			if finfo, err := os.Stat(path.Join(libs[item.Lib].Dir, item.Src)); err == nil {
+				res += finfo.Size()
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/factories"
+	"github.com/egnd/fb2lib/internal/indexer"
+	"github.com/egnd/fb2lib/internal/repos"
+)
+
+var (
+	appVersion = "debug"
+
+	showVersion = flag.Bool("version", false, "Show app version.")
+	apply       = flag.Bool("apply", false, "Remove found books from the index, only report is printed otherwise.")
+	libName     = flag.String("lib", "", "Check books of the library only.")
+	batchSize   = flag.Int("batch", 1000, "Books batch size for the summary rebuild.")
+	cfgPath     = flag.String("config", "configs/app.yml", "Configuration file path.")
+	cfgPrefix   = flag.String("env-prefix", "FBL", "Prefix for env variables.")
+)
+
+func main() {
+	startTS := time.Now()
+
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(appVersion)
+		return
+	}
+
+	cfg := factories.NewViperCfg(*cfgPath, *cfgPrefix)
+	logger := factories.NewZerolog(cfg, os.Stderr)
+
+	libs, err := entities.NewLibraries("libraries", cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("init libraries")
+	}
+
+	if _, ok := libs[*libName]; *libName != "" && !ok {
+		logger.Fatal().Str("lib", *libName).Msg("undefined lib name")
+	}
+
+	rules, err := entities.NewIndexRules("indexer.rules", cfg, libs)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("init index rules")
+	}
+
+	ctx, stop := factories.NewShutdownContext(logger)
+	defer stop()
+
+	dbDir := cfg.GetString("adapters.leveldb.dir")
+	repoBooks := repos.NewBooksLevelBleve(0,
+		map[repos.BucketType]*leveldb.DB{
+			repos.BucketBooks:     factories.NewLevelDB(dbDir, "books"),
+			repos.BucketAuthors:   factories.NewLevelDB(dbDir, "authors"),
+			repos.BucketSeries:    factories.NewLevelDB(dbDir, "series"),
+			repos.BucketGenres:    factories.NewLevelDB(dbDir, "genres"),
+			repos.BucketGenreCats: factories.NewLevelDB(dbDir, "genre_cats"),
+			repos.BucketTags:      factories.NewLevelDB(dbDir, "tags"),
+			repos.BucketLibs:      factories.NewLevelDB(dbDir, "libs"),
+			repos.BucketLangs:     factories.NewLevelDB(dbDir, "langs"),
+			repos.BucketAuthReg:   factories.NewLevelDB(dbDir, "authors_reg"),
+			repos.BucketAliases:   factories.NewLevelDB(dbDir, "aliases"),
+			repos.BucketDocs:      factories.NewLevelDB(dbDir, "docs"),
+		},
+		factories.NewBleveIndex(cfg.GetString("adapters.bleve.dir"), "books", entities.NewBookIndexMapping()),
+		jsoniter.ConfigCompatibleWithStandardLibrary.Marshal,
+		jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal,
+		logger,
+	).SetAuthorsAliases(entities.NewAuthorAliases("authors.aliases", cfg))
+	defer repoBooks.Close()
+
+	var cntTotal int
+
+	removed := map[string]struct{}{}
+	byFields := map[string]int{}
+
+	if err = repoBooks.IterateOver(ctx, func(book *entities.Book) error {
+		if *libName != "" && book.Lib != *libName {
+			return nil
+		}
+
+		cntTotal++
+
+		var mismatch *entities.ErrRuleMismatch
+		if err := rules.Check(book); errors.As(err, &mismatch) {
+			removed[book.ID] = struct{}{}
+			byFields[string(mismatch.Field)]++
+			PrintBook(os.Stdout, book, mismatch)
+		}
+
+		return nil
+	}); err != nil {
+		logger.Error().Err(err).Msg("iterating over books")
+		return
+	}
+
+	logger.Info().Int("books", cntTotal).Int("mismatched", len(removed)).Interface("by_fields", byFields).
+		Dur("dur", time.Since(startTS)).Msg("index rules check finished")
+
+	if !*apply || len(removed) == 0 {
+		return
+	}
+
+	cnt, err := repoBooks.RemoveBooks(ctx, removed)
+	if err != nil {
+		logger.Error().Err(err).Int("removed", cnt).Msg("remove books")
+		return
+	}
+
+	logger.Info().Int("removed", cnt).Msg("books removed")
+
+	indexer.RefreshSummary(ctx, repoBooks, *batchSize, logger)
+}
+
+func PrintBook(out io.Writer, book *entities.Book, mismatch *entities.ErrRuleMismatch) {
+	fmt.Fprintf(out, "%s [%s] %s (%s)\n", book.ID, book.Lib, book.Src, book.Info.Title)
+	fmt.Fprintf(out, "  %s\n", mismatch)
+}
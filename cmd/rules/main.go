@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/factories"
+	"github.com/egnd/fb2lib/internal/tasks"
+	"github.com/egnd/fb2lib/pkg/archive"
+)
+
+var (
+	appVersion = "debug"
+
+	showVersion = flag.Bool("version", false, "Show app version.")
+	libName     = flag.String("lib", "", "Check rules of the library, it is detected by the file path by default.")
+	cfgPath     = flag.String("config", "configs/app.yml", "Configuration file path.")
+	cfgPrefix   = flag.String("env-prefix", "FBL", "Prefix for env variables.")
+)
+
+var errItemFound = errors.New("item found")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] test <file>\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Explains why the book would be indexed or skipped by index rules. The file could be")
+		fmt.Fprintln(flag.CommandLine.Output(), "fb2 or epub book, compressed book or archive item, e.g. lib/arch.zip/book.fb2.")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(appVersion)
+		return
+	}
+
+	if flag.NArg() != 2 || flag.Arg(0) != "test" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := factories.NewViperCfg(*cfgPath, *cfgPrefix)
+	logger := factories.NewZerolog(cfg, os.Stderr)
+
+	libs, err := entities.NewLibraries("libraries", cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("init libraries")
+	}
+
+	rules, err := entities.NewIndexRules("indexer.rules", cfg, libs)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("init index rules")
+	}
+
+	filePath, err := filepath.Abs(flag.Arg(1))
+	if err != nil {
+		logger.Fatal().Err(err).Msg("book path")
+	}
+
+	lib, err := GetLibrary(libs, *libName, filePath)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("define library")
+	}
+
+	book, err := ReadBook(filePath, lib)
+	if err != nil {
+		logger.Fatal().Err(err).Str("file", filePath).Msg("read book")
+	}
+
+	if cfg.GetBool("indexer.detect_lang") {
+		book.DetectLang()
+	}
+
+	PrintExplain(os.Stdout, book, rules.Explain(book))
+}
+
+// GetLibrary returns library by the name or the one, which directory contains the file. Library without name and
+// rules is returned if the file is out of libraries.
+func GetLibrary(libs entities.Libraries, name string, filePath string) (entities.Library, error) {
+	if name != "" {
+		lib, ok := libs[name]
+		if !ok {
+			return lib, fmt.Errorf("undefined lib name %s", name)
+		}
+
+		return lib, nil
+	}
+
+	for _, lib := range libs {
+		dir, err := filepath.Abs(lib.Dir)
+		if err != nil {
+			return lib, err
+		}
+
+		if strings.HasPrefix(filePath, dir+string(filepath.Separator)) {
+			return lib, nil
+		}
+	}
+
+	return entities.Library{Encoder: entities.LibEncodeParser}, nil
+}
+
+// ReadBook parses the book file, the file could be plain book, compressed book file or archive item.
+func ReadBook(filePath string, lib entities.Library) (*entities.Book, error) {
+	book := &entities.Book{Lib: lib.Name, Src: filePath}
+	// the book is opened by its absolute path
+	libs := entities.Libraries{lib.Name: entities.Library{Name: lib.Name}}
+
+	switch archivePath, kind := book.Archive(); {
+	case kind == "":
+		finfo, err := os.Stat(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		book.Size = uint64(finfo.Size())
+	case kind != entities.BookContainerGzip:
+		if err := LocateItem(book, archivePath, kind); err != nil {
+			return nil, err
+		}
+	}
+
+	reader, err := libs.OpenBook(book)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if book.Size == 0 {
+		book.Size = uint64(len(data))
+	}
+
+	if book.Format() == entities.BookFormatEPUB {
+		epub, err := entities.ParseEPUB(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+
+		book.ReadEPUB(epub)
+
+		return book, nil
+	}
+
+	_, err = tasks.ReadFB2Book(data, book, lib.Encoder)
+
+	return book, err
+}
+
+// LocateItem sets locator of the archive item, the first item is located for compressed book files.
+func LocateItem(book *entities.Book, archivePath string, kind string) error {
+	_, _, itemName := archive.Split(book.Src)
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	finfo, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	items, err := archive.New(kind, file, finfo.Size())
+	if err != nil {
+		return err
+	}
+
+	err = items.Walk(func(item archive.Item, reader io.ReadCloser) error {
+		reader.Close()
+
+		if itemName != "" && path.Clean(item.Name) != path.Clean(itemName) {
+			return nil
+		}
+
+		book.Offset = item.Offset
+		book.Size = item.Size
+		book.SizeCompressed = item.SizeCompressed
+		book.Method = item.Method
+		book.CRC32 = item.CRC32
+
+		return errItemFound
+	})
+
+	switch {
+	case errors.Is(err, errItemFound):
+		return nil
+	case err != nil:
+		return err
+	default:
+		return fmt.Errorf("item %s is not found at %s", itemName, archivePath)
+	}
+}
+
+func PrintExplain(out io.Writer, book *entities.Book, results []entities.RuleResult) {
+	values := entities.NewRuleValues(book)
+
+	fmt.Fprintf(out, "book: %s\n", book.Info.Title)
+	fmt.Fprintf(out, "  src: %s\n", book.Src)
+
+	for _, field := range entities.RuleFields() {
+		switch {
+		case field == entities.IdxFSize:
+			fmt.Fprintf(out, "  %s: %s\n", field, humanize.IBytes(uint64(values.Number(field))))
+		case values.Number(field) != 0:
+			fmt.Fprintf(out, "  %s: %v\n", field, values.Number(field))
+		case len(values.Strings(field)) > 0:
+			fmt.Fprintf(out, "  %s: %s\n", field, strings.Join(values.Strings(field), ", "))
+		}
+	}
+
+	var skipped *entities.RuleResult
+
+	fmt.Fprintln(out, "rules:")
+
+	if len(results) == 0 {
+		fmt.Fprintln(out, "  no rules")
+	}
+
+	for k, res := range results {
+		status := "pass"
+		if !res.Passed {
+			status = "FAIL"
+
+			if skipped == nil {
+				skipped = &results[k]
+			}
+		}
+
+		fmt.Fprintf(out, "  %s [%s] %s %s (%s: %s)\n", status, res.Set, res.Kind, res.Cond, matchedStr(res.Matched), res.Value)
+	}
+
+	if skipped != nil {
+		fmt.Fprintf(out, "result: skipped by [%s] %s %s\n", skipped.Set, skipped.Kind, skipped.Cond)
+	} else {
+		fmt.Fprintln(out, "result: indexed")
+	}
+}
+
+func matchedStr(matched bool) string {
+	if matched {
+		return "matched"
+	}
+
+	return "not matched"
+}
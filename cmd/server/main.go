@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
 	"github.com/syndtr/goleveldb/leveldb"
 
 	"github.com/egnd/fb2lib/internal/entities"
 	"github.com/egnd/fb2lib/internal/factories"
+	"github.com/egnd/fb2lib/internal/indexer"
 	"github.com/egnd/fb2lib/internal/repos"
+	"github.com/egnd/fb2lib/pkg/echoext"
 	"github.com/egnd/go-pipeline/pools"
 )
 
@@ -33,29 +41,71 @@ func main() {
 	cfg := factories.NewViperCfg(*cfgPath, *cfgPrefix)
 	logger := factories.NewZerolog(cfg, os.Stderr)
 
+	ctx, stop := factories.NewShutdownContext(logger)
+	defer stop()
+
 	libs, err := entities.NewLibraries("libraries", cfg)
 	if err != nil {
 		panic(err)
 	}
 
 	repoLibrary := repos.NewLibraryFs(libs, pools.NewSemaphore(20, nil), logger)
-	repoBooks := repos.NewBooksLevelBleve(0,
+
+	// marks are closed after books, hooks of the last books batch save checkpoints on close
+	repoMarks := repos.NewLibMarks(factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "marks"))
+	defer repoMarks.Close()
+
+	repoBooks := repos.NewBooksLevelBleve(cfg.GetInt("indexer.batch_size"),
 		map[repos.BucketType]*leveldb.DB{
-			repos.BucketBooks:   factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "books"),
-			repos.BucketAuthors: factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "authors"),
-			repos.BucketSeries:  factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "series"),
-			repos.BucketGenres:  factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "genres"),
-			repos.BucketLibs:    factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "libs"),
-			repos.BucketLangs:   factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "langs"),
+			repos.BucketBooks:     factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "books"),
+			repos.BucketAuthors:   factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "authors"),
+			repos.BucketSeries:    factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "series"),
+			repos.BucketGenres:    factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "genres"),
+			repos.BucketGenreCats: factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "genre_cats"),
+			repos.BucketTags:      factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "tags"),
+			repos.BucketLibs:      factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "libs"),
+			repos.BucketLangs:     factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "langs"),
+			repos.BucketAuthReg:   factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "authors_reg"),
+			repos.BucketAliases:   factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "aliases"),
+			repos.BucketDocs:      factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "docs"),
 		},
 		factories.NewBleveIndex(cfg.GetString("adapters.bleve.dir"), "books", entities.NewBookIndexMapping()),
 		jsoniter.ConfigCompatibleWithStandardLibrary.Marshal,
 		jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal,
 		logger,
-	)
+	).SetAuthorsAliases(entities.NewAuthorAliases("authors.aliases", cfg))
 	defer repoBooks.Close()
 
-	server, err := factories.NewEchoServer(appVersion, libs, cfg, logger, repoBooks, repoLibrary)
+	repoFailures := repos.NewIndexFailures(factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "failures"),
+		jsoniter.ConfigCompatibleWithStandardLibrary.Marshal,
+		jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal,
+	)
+	defer repoFailures.Close()
+
+	repoRuns := repos.NewIndexRuns(factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "runs"),
+		jsoniter.ConfigCompatibleWithStandardLibrary.Marshal,
+		jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal,
+	)
+	defer repoRuns.Close()
+
+	factories.RegisterStoreMetrics(cfg, repoBooks)
+
+	idx, err := indexer.NewIndexer(cfg, libs, repoBooks, repoMarks, repoFailures, repoRuns, nil, nil, logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("init indexer")
+	}
+	defer idx.Close()
+
+	var watcher *indexer.Watcher
+	if cfg.GetBool("indexer.watch.enabled") {
+		var stopWatcher func()
+		watcher, stopWatcher = RunWatcher(ctx, cfg, libs, idx, repoBooks, repoMarks, repoFailures, logger)
+		defer stopWatcher()
+	}
+
+	server, err := factories.NewEchoServer(ctx, appVersion, libs, cfg, logger,
+		repoBooks, repoLibrary, repoFailures, repoRuns, idx,
+	)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("init http server")
 	}
@@ -65,18 +115,145 @@ func main() {
 	// 	panic(err)
 	// }
 
+	handler := echoext.NewSwitchHandler(server)
+	httpServer := &http.Server{Addr: fmt.Sprintf(":%d", cfg.GetInt("server.port")), Handler: handler}
+
+	if cfg.GetBool("server.reload.enabled") {
+		prevCfg := cfg
+
+		factories.WatchViperCfg(ctx, *cfgPath, *cfgPrefix, cfg.GetDuration("server.reload.delay"), logger,
+			func(newCfg *viper.Viper) error {
+				err := ReloadConfig(ctx, newCfg, handler, repoBooks, repoLibrary, repoFailures, repoRuns, idx, watcher,
+					logger,
+				)
+				if err != nil {
+					return err
+				}
+
+				WarnRestartKeys(prevCfg, newCfg, logger)
+				prevCfg = newCfg
+
+				return nil
+			},
+		)
+	}
+
 	logger.Info().
 		Int("port", cfg.GetInt("server.port")).
 		Str("version", appVersion).
 		Msg("server is listening...")
 
-	if err = server.Start(fmt.Sprintf(":%d", cfg.GetInt("server.port"))); err != nil {
-		logger.Fatal().Err(err).Msg("server error")
+	go func() {
+		if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			logger.Error().Err(err).Msg("server error")
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+
+	Shutdown(httpServer, cfg.GetDuration("shutdown.timeout"), logger)
+}
+
+// Shutdown waits for active requests during the grace timeout, indexing and watching are stopped after that and
+// stores are closed by deferred calls.
+func Shutdown(server *http.Server, timeout time.Duration, logger zerolog.Logger) {
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error().Err(err).Msg("server shutdown")
 	}
 
 	logger.Info().Msg("server stopped")
 }
 
+// ReloadConfig validates the changed config and applies it: libraries and index rules are replaced, libraries
+// watcher (if any) watches new libraries dirs and http server is built again, so pages use new libraries, renderer
+// settings and theme. Nothing is changed on error.
+func ReloadConfig(ctx context.Context, cfg *viper.Viper, handler *echoext.SwitchHandler,
+	repoBooks *repos.BooksLevelBleve, repoLibrary *repos.LibraryFs, repoFailures *repos.IndexFailures,
+	repoRuns *repos.IndexRuns, idx *indexer.Indexer, watcher *indexer.Watcher, logger zerolog.Logger,
+) (err error) {
+	// handlers panic on invalid settings
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	libs, err := entities.NewLibraries("libraries", cfg)
+	if err != nil {
+		return fmt.Errorf("libraries: %w", err)
+	}
+
+	rules, err := entities.NewIndexRules("indexer.rules", cfg, libs)
+	if err != nil {
+		return fmt.Errorf("index rules: %w", err)
+	}
+
+	server, err := factories.NewEchoServer(ctx, appVersion, libs, cfg, logger,
+		repoBooks, repoLibrary, repoFailures, repoRuns, idx,
+	)
+	if err != nil {
+		return fmt.Errorf("http server: %w", err)
+	}
+
+	repoLibrary.SetLibs(libs)
+	idx.SetLibs(libs, rules)
+	handler.Set(server)
+
+	if watcher != nil {
+		if err := watcher.SetLibs(libs); err != nil {
+			logger.Error().Err(err).Msg("watch reloaded libraries")
+		}
+	}
+
+	return nil
+}
+
+// WarnRestartKeys logs changed settings, which are applied after the restart only.
+func WarnRestartKeys(prevCfg, cfg *viper.Viper, logger zerolog.Logger) {
+	keys := []string{
+		"server.port", "server.reload", "logs", "adapters", "shutdown", "metrics", "renderer.lang",
+		"indexer.threads_cnt", "indexer.read_threads", "indexer.parse_threads", "indexer.read_buff",
+		"indexer.parse_buff", "indexer.batch_size", "indexer.detect_lang", "indexer.watch", "authors.aliases",
+	}
+
+	for _, key := range keys {
+		if fmt.Sprint(prevCfg.Get(key)) != fmt.Sprint(cfg.Get(key)) {
+			logger.Warn().Str("key", key).Msg("changed setting is applied after restart")
+		}
+	}
+}
+
+// RunWatcher starts indexing of libraries changes until the context is canceled, returned func waits for it.
+func RunWatcher(ctx context.Context, cfg *viper.Viper, libs entities.Libraries, idx *indexer.Indexer,
+	repoBooks *repos.BooksLevelBleve, repoMarks *repos.LibMarks, repoFailures *repos.IndexFailures,
+	logger zerolog.Logger,
+) (*indexer.Watcher, func()) {
+	watcher, err := indexer.NewWatcher(cfg, libs, idx, repoBooks, repoMarks, repoFailures, logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("init watcher")
+	}
+
+	go func() {
+		if err := watcher.Run(ctx); err != nil {
+			logger.Error().Err(err).Msg("watch libraries")
+		}
+	}()
+
+	return watcher, func() {
+		if err := watcher.Close(); err != nil {
+			logger.Error().Err(err).Msg("stop watching")
+		}
+	}
+}
+
 // func cacheWarmup(logger zerolog.Logger, cfg *viper.Viper,
 // 	repoInfo entities.IBooksInfoRepo,
 // ) error {
@@ -18,9 +18,8 @@ import (
 
 	"github.com/egnd/fb2lib/internal/entities"
 	"github.com/egnd/fb2lib/internal/factories"
+	"github.com/egnd/fb2lib/internal/indexer"
 	"github.com/egnd/fb2lib/internal/repos"
-	"github.com/egnd/go-pipeline/pools"
-	"github.com/egnd/go-pipeline/tasks"
 )
 
 var (
@@ -32,21 +31,10 @@ var (
 	cfgPath     = flag.String("config", "configs/app.yml", "Configuration file path.")
 	cfgPrefix   = flag.String("env-prefix", "FBL", "Prefix for env variables.")
 	profiler    = flag.String("pprof", "", "Enable profiler (mem,allocs,heap,cpu,trace,goroutine,mutex,block,thread).")
-
-	resetStats = func() map[repos.BucketType]entities.ItemFreqMap {
-		return map[repos.BucketType]entities.ItemFreqMap{
-			repos.BucketGenres:  make(entities.ItemFreqMap, *batchSize),
-			repos.BucketAuthors: make(entities.ItemFreqMap, *batchSize),
-			repos.BucketSeries:  make(entities.ItemFreqMap, *batchSize),
-			repos.BucketLangs:   make(entities.ItemFreqMap, *batchSize),
-			repos.BucketLibs:    make(entities.ItemFreqMap, *batchSize),
-		}
-	}
 )
 
 func main() {
 	var (
-		step     int
 		cntTotal uint64
 		barTotal *mpb.Bar
 		startTS  = time.Now()
@@ -62,6 +50,9 @@ func main() {
 	cfg := factories.NewViperCfg(*cfgPath, *cfgPrefix)
 	logger := factories.NewZerolog(cfg, os.Stderr)
 
+	ctx, stop := factories.NewShutdownContext(logger)
+	defer stop()
+
 	defer func() {
 		logger.Info().Uint64("cnt", cntTotal).Dur("dur", time.Since(startTS)).Msg("summary build finished")
 	}()
@@ -71,96 +62,45 @@ func main() {
 	}
 
 	os.RemoveAll(path.Join(cfg.GetString("adapters.leveldb.dir"), "authors"))
+	os.RemoveAll(path.Join(cfg.GetString("adapters.leveldb.dir"), "authors_reg"))
 	os.RemoveAll(path.Join(cfg.GetString("adapters.leveldb.dir"), "series"))
 	os.RemoveAll(path.Join(cfg.GetString("adapters.leveldb.dir"), "genres"))
+	os.RemoveAll(path.Join(cfg.GetString("adapters.leveldb.dir"), "genre_cats"))
+	os.RemoveAll(path.Join(cfg.GetString("adapters.leveldb.dir"), "tags"))
 	os.RemoveAll(path.Join(cfg.GetString("adapters.leveldb.dir"), "libs"))
 	os.RemoveAll(path.Join(cfg.GetString("adapters.leveldb.dir"), "langs"))
 
 	repoBooks := repos.NewBooksLevelBleve(0,
 		map[repos.BucketType]*leveldb.DB{
-			repos.BucketBooks:   factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "books"),
-			repos.BucketAuthors: factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "authors"),
-			repos.BucketSeries:  factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "series"),
-			repos.BucketGenres:  factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "genres"),
-			repos.BucketLibs:    factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "libs"),
-			repos.BucketLangs:   factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "langs"),
+			repos.BucketBooks:     factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "books"),
+			repos.BucketAuthors:   factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "authors"),
+			repos.BucketSeries:    factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "series"),
+			repos.BucketGenres:    factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "genres"),
+			repos.BucketGenreCats: factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "genre_cats"),
+			repos.BucketTags:      factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "tags"),
+			repos.BucketLibs:      factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "libs"),
+			repos.BucketLangs:     factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "langs"),
+			repos.BucketAuthReg:   factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "authors_reg"),
+			repos.BucketAliases:   factories.NewLevelDB(cfg.GetString("adapters.leveldb.dir"), "aliases"),
 		},
 		factories.NewBleveIndex(cfg.GetString("adapters.bleve.dir"), "books", entities.NewBookIndexMapping()),
 		jsoniter.ConfigCompatibleWithStandardLibrary.Marshal,
 		jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal,
 		logger,
-	)
+	).SetAuthorsAliases(entities.NewAuthorAliases("authors.aliases", cfg))
 	defer repoBooks.Close()
 
 	if !*hideBar {
 		barTotal = GetProgressBar(mpb.New(mpb.WithOutput(os.Stdout)), cfg, &logger, repoBooks)
 	}
 
-	stats := resetStats()
-
-	pipe := pools.NewSemaphore(len(stats), nil)
-	defer pipe.Close()
-
-	if err := repoBooks.IterateOver(func(book *entities.Book) error {
-		defer func() {
-			if barTotal != nil {
-				barTotal.Increment()
-			}
-		}()
-
-		logger.Debug().Str("book", book.ID).Msg("calculate")
-
-		for _, k := range book.Genres() {
-			stats[repos.BucketGenres].Put(k, 1)
-		}
-		for _, k := range book.Authors() {
-			stats[repos.BucketAuthors].Put(k, 1)
-		}
-		for _, k := range book.Series() {
-			stats[repos.BucketSeries].Put(k, 1)
-		}
-		stats[repos.BucketLangs].Put(book.Info.Lang, 1)
-		stats[repos.BucketLibs].Put(book.Lib, 1)
-
-		step++
-		cntTotal++
-		if step < *batchSize {
-			return nil
-		}
-
-		for bucket, freq := range stats {
-			bucket, freq := bucket, freq
-			pipe.Push(tasks.NewFunc(string(bucket), func() error {
-				if err := repoBooks.AppendFreqs(bucket, freq); err != nil {
-					logger.Warn().Err(err).Str("bucket", string(bucket)).Int("len", len(freq)).Msg("batch")
-				} else {
-					logger.Debug().Str("bucket", string(bucket)).Int("len", len(freq)).Msg("batch")
-				}
-
-				return nil
-			}))
-		}
-		pipe.Wait()
-		stats = resetStats()
-		step = 0
-		return nil
-	}); err != nil {
-		logger.Error().Err(err).Msg("iterating over books")
-	}
+	cntTotal = indexer.BuildSummary(ctx, repoBooks, *batchSize, barTotal, logger)
 
-	for bucket, freq := range stats {
-		bucket, freq := bucket, freq
-		pipe.Push(tasks.NewFunc(string(bucket), func() error {
-			if err := repoBooks.AppendFreqs(bucket, freq); err != nil {
-				logger.Warn().Err(err).Str("bucket", string(bucket)).Int("len", len(freq)).Msg("batch")
-			} else {
-				logger.Debug().Str("bucket", string(bucket)).Int("len", len(freq)).Msg("batch")
-			}
-
-			return nil
-		}))
+	if ctx.Err() != nil {
+		logger.Warn().Msg("summary build interrupted, run it again to complete the summary")
+		return
 	}
-	pipe.Wait()
+
 	time.Sleep(100 * time.Millisecond)
 }
 
@@ -0,0 +1,278 @@
+package entities
+
+import (
+	"encoding/xml"
+	"errors"
+	"html"
+	"io"
+	"strings"
+
+	htmlparse "golang.org/x/net/html"
+)
+
+// annotTags maps fb2 and html tags of annotation to allowed html tags, tags out of the map are dropped with
+// their content kept.
+var annotTags = map[string]string{
+	"p":             "p",
+	"div":           "p",
+	"subtitle":      "h5",
+	"h5":            "h5",
+	"emphasis":      "em",
+	"em":            "em",
+	"i":             "em",
+	"strong":        "strong",
+	"b":             "strong",
+	"strikethrough": "s",
+	"s":             "s",
+	"sub":           "sub",
+	"sup":           "sup",
+	"code":          "code",
+	"cite":          "blockquote",
+	"blockquote":    "blockquote",
+	"text-author":   "p",
+	"stanza":        "p",
+	"empty-line":    "br",
+	"br":            "br",
+	"v":             "v",
+	"a":             "a",
+	"ul":            "ul",
+	"ol":            "ol",
+	"li":            "li",
+}
+
+// annotSkipTags are dropped with their content.
+var annotSkipTags = map[string]struct{}{
+	"script": {}, "style": {}, "iframe": {}, "object": {}, "embed": {}, "image": {}, "img": {}, "binary": {},
+}
+
+var annotBlockTags = map[string]struct{}{
+	"p": {}, "h5": {}, "blockquote": {}, "ul": {}, "ol": {}, "li": {},
+}
+
+// SanitizeAnnotation converts fb2 annotation markup to safe html subset and returns plain text of it too.
+func SanitizeAnnotation(raw string) (htmlRes string, textRes string) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", ""
+	}
+
+	wrapped := "<annotation>" + raw + "</annotation>"
+
+	decoder := xml.NewDecoder(strings.NewReader(wrapped))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	var (
+		htmlBuf, textBuf strings.Builder
+		opened           []string
+		skipDepth        int
+		inBlock          int
+	)
+
+	openBlock := func() {
+		if inBlock == 0 {
+			htmlBuf.WriteString("<p>")
+			opened = append(opened, "p")
+			inBlock++
+		}
+	}
+
+	writeText := func(str string) {
+		if strings.TrimSpace(str) != "" {
+			openBlock()
+		}
+
+		htmlBuf.WriteString(html.EscapeString(str))
+		textBuf.WriteString(str)
+	}
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			// malformed markup, only the text of it is kept
+			if textRes = annotFallbackText(raw); textRes == "" {
+				return "", ""
+			}
+
+			return "<p>" + strings.ReplaceAll(html.EscapeString(textRes), "\n", "</p><p>") + "</p>", textRes
+		}
+
+		switch typed := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(typed.Name.Local)
+
+			if _, ok := annotSkipTags[name]; ok || skipDepth > 0 {
+				skipDepth++
+				continue
+			}
+
+			tag, ok := annotTags[name]
+			if !ok {
+				opened = append(opened, "")
+				continue
+			}
+
+			if _, block := annotBlockTags[tag]; block {
+				textBuf.WriteRune('\n')
+
+				if len(opened) > 0 && opened[len(opened)-1] == "p" {
+					// paragraphs can't contain blocks
+					htmlBuf.WriteString("</p>")
+					opened[len(opened)-1] = ""
+					inBlock--
+				}
+			} else {
+				openBlock()
+			}
+
+			switch tag {
+			case "v":
+			case "br":
+				htmlBuf.WriteString("<br>")
+				textBuf.WriteRune('\n')
+				tag = ""
+			case "a":
+				if href := annotLinkHref(typed.Attr); href != "" {
+					htmlBuf.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow noopener" target="_blank">`)
+				} else {
+					tag = ""
+				}
+			default:
+				htmlBuf.WriteString("<" + tag + ">")
+			}
+
+			if _, block := annotBlockTags[tag]; block {
+				inBlock++
+			}
+
+			opened = append(opened, tag)
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+
+			if len(opened) == 0 {
+				continue
+			}
+
+			tag := opened[len(opened)-1]
+			opened = opened[:len(opened)-1]
+
+			switch tag {
+			case "":
+				continue
+			case "v":
+				htmlBuf.WriteString("<br>")
+				textBuf.WriteRune('\n')
+
+				continue
+			}
+
+			htmlBuf.WriteString("</" + tag + ">")
+
+			if _, block := annotBlockTags[tag]; block {
+				inBlock--
+				textBuf.WriteRune('\n')
+			}
+		case xml.CharData:
+			if skipDepth == 0 {
+				writeText(string(typed))
+			}
+		}
+	}
+
+	for k := len(opened) - 1; k >= 0; k-- {
+		if opened[k] != "" && opened[k] != "v" {
+			htmlBuf.WriteString("</" + opened[k] + ">")
+		}
+	}
+
+	return htmlBuf.String(), annotCollapseText(textBuf.String())
+}
+
+// AnnotationText returns plain text of annotation.
+func (m *BookMeta) AnnotationText() string {
+	_, res := SanitizeAnnotation(m.Annotation)
+
+	return res
+}
+
+func annotLinkHref(attrs []xml.Attr) string {
+	for _, attr := range attrs {
+		if attr.Name.Local != "href" {
+			continue
+		}
+
+		href := strings.TrimSpace(attr.Value)
+		lower := strings.ToLower(href)
+
+		if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "mailto:") {
+			return href
+		}
+	}
+
+	return ""
+}
+
+func annotFallbackText(raw string) string {
+	var buf strings.Builder
+
+	tokenizer := htmlparse.NewTokenizer(strings.NewReader(raw))
+	skipDepth := 0
+
+	for {
+		switch tokenizer.Next() {
+		case htmlparse.ErrorToken:
+			return annotCollapseText(buf.String())
+		case htmlparse.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if annotHasContent(string(name)) {
+				skipDepth++
+			}
+
+			buf.WriteRune('\n')
+		case htmlparse.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if annotHasContent(string(name)) && skipDepth > 0 {
+				skipDepth--
+			}
+
+			buf.WriteRune('\n')
+		case htmlparse.SelfClosingTagToken:
+			buf.WriteRune('\n')
+		case htmlparse.TextToken:
+			if skipDepth == 0 {
+				buf.Write(tokenizer.Text())
+			}
+		}
+	}
+}
+
+// annotHasContent reports whether skipped html tag is not a void one.
+func annotHasContent(name string) bool {
+	switch strings.ToLower(name) {
+	case "script", "style", "iframe", "object":
+		return true
+	default:
+		return false
+	}
+}
+
+func annotCollapseText(str string) string {
+	lines := strings.Split(str, "\n")
+	res := lines[:0]
+
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			res = append(res, line)
+		}
+	}
+
+	return strings.Join(res, "\n")
+}
@@ -0,0 +1,273 @@
+package entities
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/egnd/go-xmlparse"
+	"github.com/egnd/go-xmlparse/fb2"
+	"github.com/essentialkaos/translit/v2"
+	"github.com/spf13/viper"
+)
+
+var (
+	authorKeyPattern  = regexp.MustCompile(`[^a-z0-9]+`)
+	authorNickPattern = regexp.MustCompile(`\(.*\)`)
+)
+
+type AuthorRef struct {
+	Name  string `json:"name,omitempty"`
+	Key   string `json:"key,omitempty"`
+	ExtID string `json:"eid,omitempty"`
+}
+
+func NewAuthorRef(data fb2.Author) AuthorRef {
+	return AuthorRef{
+		Name: data.String(),
+		Key: NewAuthorKey(
+			xmlparse.GetStrFrom(data.LastName),
+			xmlparse.GetStrFrom(data.FirstName),
+			xmlparse.GetStrFrom(data.Nickname),
+		),
+		ExtID: strings.ToLower(strings.TrimSpace(xmlparse.GetStrFrom(data.ID))),
+	}
+}
+
+func NewAuthorRefFromName(name string) AuthorRef {
+	name = strings.TrimSpace(name)
+	parts := strings.Fields(authorNickPattern.ReplaceAllString(name, ""))
+
+	var last, first string
+	switch len(parts) {
+	case 0:
+	case 1:
+		last = parts[0]
+	default:
+		last, first = parts[0], parts[1]
+	}
+
+	return AuthorRef{Name: name, Key: NewAuthorKey(last, first, strings.Trim(name, "() "))}
+}
+
+// NewAuthorKey builds transliterated author key from last and first names, middle name is ignored.
+func NewAuthorKey(last, first, nick string) string {
+	normalize := func(val string) string {
+		return authorKeyPattern.ReplaceAllString(strings.ToLower(translit.EncodeToICAO(val)), "")
+	}
+
+	last, first = normalize(last), normalize(first)
+
+	switch {
+	case last != "" && first != "":
+		return last + "-" + first
+	case last != "":
+		return last
+	case first != "":
+		return first
+	default:
+		return normalize(nick)
+	}
+}
+
+type AuthorAliases map[string]string
+
+func NewAuthorAliases(cfgKey string, cfg *viper.Viper) AuthorAliases {
+	res := AuthorAliases{}
+
+	for canonical, aliases := range cfg.GetStringMapStringSlice(cfgKey) {
+		canonicalKey := NewAuthorRefFromName(canonical).Key
+		if canonicalKey == "" {
+			continue
+		}
+
+		for _, alias := range aliases {
+			if key := NewAuthorRefFromName(alias).Key; key != "" && key != canonicalKey {
+				res[key] = canonicalKey
+			}
+		}
+	}
+
+	return res
+}
+
+func (a AuthorAliases) Resolve(key string) string {
+	if val, ok := a[key]; ok {
+		return val
+	}
+
+	return key
+}
+
+type AuthorVariant struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	Freq int    `json:"frq,omitempty"`
+}
+
+type Author struct {
+	ID       string          `json:"id"`
+	Val      string          `json:"val"`
+	Freq     int             `json:"frq,omitempty"`
+	Variants []AuthorVariant `json:"vars,omitempty"`
+	ExtIDs   []string        `json:"eids,omitempty"`
+}
+
+func (a *Author) Keys() []string {
+	index := make(map[string]struct{}, len(a.Variants)+1)
+	res := make([]string, 0, len(a.Variants)+1)
+
+	for _, key := range append([]string{a.ID}, a.VariantsKeys()...) {
+		if _, ok := index[key]; !ok && key != "" {
+			index[key] = struct{}{}
+			res = append(res, key)
+		}
+	}
+
+	return res
+}
+
+func (a *Author) VariantsKeys() []string {
+	res := make([]string, 0, len(a.Variants))
+
+	for _, item := range a.Variants {
+		res = append(res, item.Key)
+	}
+
+	return res
+}
+
+func (a *Author) Names() []string {
+	res := make([]string, 0, len(a.Variants))
+
+	for _, item := range a.Variants {
+		res = append(res, item.Name)
+	}
+
+	return res
+}
+
+func (a *Author) AddVariant(ref AuthorRef, freq int) {
+	if ref.ExtID != "" && !SliceHasString(a.ExtIDs, ref.ExtID) {
+		a.ExtIDs = append(a.ExtIDs, ref.ExtID)
+	}
+
+	for k, item := range a.Variants {
+		if item.Name == ref.Name && item.Key == ref.Key {
+			a.Variants[k].Freq += freq
+			return
+		}
+	}
+
+	a.Variants = append(a.Variants, AuthorVariant{Name: ref.Name, Key: ref.Key, Freq: freq})
+}
+
+func (a *Author) Merge(other *Author) {
+	for _, item := range other.Variants {
+		a.AddVariant(AuthorRef{Name: item.Name, Key: item.Key}, item.Freq)
+	}
+
+	for _, extID := range other.ExtIDs {
+		if !SliceHasString(a.ExtIDs, extID) {
+			a.ExtIDs = append(a.ExtIDs, extID)
+		}
+	}
+
+	a.Refresh()
+}
+
+// Split moves variants with the key to a new author.
+func (a *Author) Split(key string) *Author {
+	res := &Author{ID: key}
+	variants := a.Variants[:0]
+
+	for _, item := range a.Variants {
+		if item.Key == key {
+			res.Variants = append(res.Variants, item)
+		} else {
+			variants = append(variants, item)
+		}
+	}
+
+	a.Variants = variants
+	a.Refresh()
+	res.Refresh()
+
+	return res
+}
+
+// Refresh recalculates author frequency and picks most frequent name as canonical.
+func (a *Author) Refresh() {
+	sort.SliceStable(a.Variants, func(i, j int) bool {
+		return a.Variants[i].Freq > a.Variants[j].Freq
+	})
+
+	a.Freq = 0
+	a.Val = ""
+
+	for _, item := range a.Variants {
+		a.Freq += item.Freq
+
+		if a.Val == "" && item.Key == a.ID {
+			a.Val = item.Name
+		}
+	}
+
+	if a.Val == "" && len(a.Variants) > 0 {
+		a.Val = a.Variants[0].Name
+	}
+}
+
+type AuthorsRegistry map[string]*Author
+
+func (r AuthorsRegistry) Put(ref AuthorRef, resolve func(string) string, freq int) {
+	if ref.Key == "" {
+		return
+	}
+
+	authorID := resolve(ref.Key)
+
+	if _, ok := r[authorID]; !ok {
+		r[authorID] = &Author{ID: authorID}
+	}
+
+	r[authorID].AddVariant(ref, freq)
+}
+
+// Compact merges authors sharing the same external ids and refreshes canonical names.
+func (r AuthorsRegistry) Compact() {
+	owners := map[string]string{}
+
+	ids := make([]string, 0, len(r))
+	for id := range r {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		author := r[id]
+
+		for _, extID := range author.ExtIDs {
+			ownerID, ok := owners[extID]
+			if !ok {
+				owners[extID] = id
+				continue
+			}
+
+			if owner, exists := r[ownerID]; exists && ownerID != id {
+				owner.Merge(author)
+				delete(r, id)
+
+				for _, item := range author.ExtIDs {
+					owners[item] = ownerID
+				}
+
+				break
+			}
+		}
+	}
+
+	for _, author := range r {
+		author.Refresh()
+	}
+}
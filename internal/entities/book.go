@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"crypto/md5"
 	"encoding/hex"
+	"math"
 	"sort"
 	"strings"
 
 	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
 	"github.com/blevesearch/bleve/v2/mapping"
 	"github.com/egnd/go-xmlparse"
 	"github.com/egnd/go-xmlparse/fb2"
@@ -18,23 +20,27 @@ type Book struct {
 	Offset         uint64            `json:"from,omitempty"`
 	Size           uint64            `json:"size,omitempty"`
 	SizeCompressed uint64            `json:"sizec,omitempty"`
+	Method         uint16            `json:"zmethod,omitempty"`
+	CRC32          uint32            `json:"crc,omitempty"`
+	Encoding       string            `json:"enc,omitempty"`
 	Lib            string            `json:"lib,omitempty"`
 	Src            string            `json:"src,omitempty"`
 	Info           BookMeta          `json:"info,omitempty"`
 	OrigInfo       *BookMeta         `json:"oinfo,omitempty"`
 	PublInfo       []BookPublisher   `json:"pinfo,omitempty"`
+	DocInfo        *BookDocInfo      `json:"dinfo,omitempty"`
+	DupOf          string            `json:"dup,omitempty"`
+	Alternates     []string          `json:"alts,omitempty"`
 	Match          map[string]string `json:"-"`
 }
 
-func (b *Book) Index() (res BookIndex) {
+func (b *Book) Index(resolveAuthor func(string) string) (res BookIndex) {
 	var (
-		isbn       bytes.Buffer
 		title      bytes.Buffer
 		author     bytes.Buffer
 		translator bytes.Buffer
 		serie      bytes.Buffer
 		date       bytes.Buffer
-		genre      bytes.Buffer
 		publisher  bytes.Buffer
 	)
 
@@ -50,41 +56,59 @@ func (b *Book) Index() (res BookIndex) {
 
 	appendStr(&title, b.Info.Title)
 	appendStr(&date, b.Info.Date)
-	appendStr(&genre, b.Info.Genres...)
 	appendStr(&author, b.Info.Authors...)
 	appendStr(&translator, b.Info.Translators...)
-	appendStr(&serie, b.Info.Sequences...)
+	appendStr(&serie, b.Info.Sequences.Names()...)
 
 	if b.OrigInfo != nil {
 		appendStr(&title, b.OrigInfo.Title)
 		appendStr(&date, b.OrigInfo.Date)
-		appendStr(&genre, b.OrigInfo.Genres...)
 		appendStr(&author, b.OrigInfo.Authors...)
 		appendStr(&translator, b.OrigInfo.Translators...)
-		appendStr(&serie, b.OrigInfo.Sequences...)
+		appendStr(&serie, b.OrigInfo.Sequences.Names()...)
 	}
 
 	for _, publ := range b.PublInfo {
 		appendStr(&title, publ.Title)
 		appendStr(&date, publ.Year)
 		appendStr(&author, publ.Authors...)
-		appendStr(&serie, publ.Sequences...)
+		appendStr(&serie, publ.Sequences.Names()...)
 		appendStr(&publisher, publ.Publisher)
-		appendStr(&isbn, publ.ISBN)
 	}
 
 	res.ID = b.ID
 	res.Lang = b.Info.Lang
 	res.Lib = b.Lib
-	res.ISBN = isbn.String()
+	res.ISBN = b.ISBNTerms()
+	res.Keywords = b.Keywords()
 	res.Title = title.String()
 	res.Author = author.String()
 	res.Translator = translator.String()
 	res.Serie = serie.String()
 	res.Date = date.String()
-	res.Genre = genre.String()
+	res.Genre = strings.Join(WithParentGenres(b.Genres()), " ")
 	res.Publisher = publisher.String()
 	res.Year = ParseYear(res.Date)
+	res.Dup = b.DupOf != ""
+	res.Work = b.WorkID(resolveAuthor)
+	res.Annotation = b.Info.AnnotationText()
+
+	if b.OrigInfo != nil && b.OrigInfo.Annotation != "" {
+		res.Annotation = strings.TrimSpace(res.Annotation + "\n" + b.OrigInfo.AnnotationText())
+	}
+
+	for _, ref := range b.AuthorsRefs() {
+		if !SliceHasString(res.AuthorKeys, ref.Key) {
+			res.AuthorKeys = append(res.AuthorKeys, ref.Key)
+		}
+	}
+
+	for _, item := range b.Info.Sequences {
+		if item.Num > 0 && item.Num <= math.MaxUint16 {
+			res.SerieNum = uint16(item.Num)
+			break
+		}
+	}
 
 	return
 }
@@ -116,6 +140,32 @@ func (b *Book) Authors() []string {
 	return res
 }
 
+func (b *Book) AuthorsRefs() []AuthorRef {
+	index := make(map[string]struct{}, 6)
+	res := make([]AuthorRef, 0, 6)
+
+	appendRefs := func(meta *BookMeta) {
+		for _, ref := range meta.refs() {
+			if _, ok := index[ref.Name]; !ok && ref.Key != "" {
+				index[ref.Name] = struct{}{}
+				res = append(res, ref)
+			}
+		}
+	}
+
+	appendRefs(&b.Info)
+
+	if b.OrigInfo != nil {
+		appendRefs(b.OrigInfo)
+	}
+
+	for _, publ := range b.PublInfo {
+		appendRefs(&BookMeta{Authors: publ.Authors})
+	}
+
+	return res
+}
+
 func (b *Book) Translators() []string {
 	index := make(map[string]struct{}, 6)
 
@@ -137,6 +187,51 @@ func (b *Book) Translators() []string {
 	return res
 }
 
+// DetectLang fills missing, unknown or mismatched by script book language with the detected one.
+func (b *Book) DetectLang() bool {
+	code, confidence := DetectLang(b.Info.Title + " " + b.Info.AnnotationText())
+	if code == "" || code == b.Info.Lang || confidence < 0.6 {
+		return false
+	}
+
+	if cur := GetLang(b.Info.Lang); cur != nil && cur.Script == isoLangs[code].Script {
+		return false
+	}
+
+	if b.Info.LangOrig == "" {
+		b.Info.LangOrig = b.Info.Lang
+	}
+
+	b.Info.Lang = code
+
+	return true
+}
+
+// ISBNTerms returns canonical isbn-13 values with isbn-10 equivalents, unparsable values are kept cleaned.
+func (b *Book) ISBNTerms() []string {
+	var res []string
+
+	for _, publ := range b.PublInfo {
+		isbns, invalid := ParseISBNs(publ.ISBN)
+
+		for _, isbn := range isbns {
+			for _, term := range isbn.Terms() {
+				if !SliceHasString(res, term) {
+					res = append(res, term)
+				}
+			}
+		}
+
+		for _, term := range invalid {
+			if !SliceHasString(res, term) {
+				res = append(res, term)
+			}
+		}
+	}
+
+	return res
+}
+
 func (b *Book) Genres() []string {
 	index := make(map[string]struct{}, 6)
 
@@ -161,18 +256,18 @@ func (b *Book) Genres() []string {
 func (b *Book) Series() []string {
 	index := make(map[string]struct{}, 6)
 
-	for _, item := range b.Info.Sequences {
+	for _, item := range b.Info.Sequences.Names() {
 		index[item] = struct{}{}
 	}
 
 	if b.OrigInfo != nil {
-		for _, item := range b.OrigInfo.Sequences {
+		for _, item := range b.OrigInfo.Sequences.Names() {
 			index[item] = struct{}{}
 		}
 	}
 
 	for _, item := range b.PublInfo {
-		for _, item := range item.Sequences {
+		for _, item := range item.Sequences.Names() {
 			index[item] = struct{}{}
 		}
 	}
@@ -185,6 +280,26 @@ func (b *Book) Series() []string {
 	return res
 }
 
+func (b *Book) GetSerie(name string) *BookSerie {
+	if res := b.Info.Sequences.Get(name); res != nil {
+		return res
+	}
+
+	if b.OrigInfo != nil {
+		if res := b.OrigInfo.Sequences.Get(name); res != nil {
+			return res
+		}
+	}
+
+	for _, item := range b.PublInfo {
+		if res := item.Sequences.Get(name); res != nil {
+			return res
+		}
+	}
+
+	return nil
+}
+
 func (b *Book) Titles() []string {
 	index := make(map[string]struct{}, 6)
 
@@ -225,6 +340,10 @@ func (b *Book) ReadFB2(data *fb2.File) {
 			b.PublInfo = append(b.PublInfo, publInfo)
 			misc = append(misc, publInfo.ISBN)
 		}
+
+		if b.DocInfo == nil {
+			b.DocInfo = NewBookDocInfo(item.DocInfo, item.CustomInfo)
+		}
 	}
 
 	hasher := md5.New()
@@ -244,14 +363,16 @@ func (b *Book) ReadFB2(data *fb2.File) {
 type BookMeta struct {
 	Annotation  string      `json:"annot,omitempty"`
 	Lang        string      `json:"lang,omitempty"`
+	LangOrig    string      `json:"lango,omitempty"`
 	SrcLang     string      `json:"slang,omitempty"`
 	Title       string      `json:"title,omitempty"`
 	Keywords    string      `json:"kwds,omitempty"`
 	Date        string      `json:"date,omitempty"`
 	Genres      []string    `json:"genres,omitempty"`
 	Authors     []string    `json:"auth,omitempty"`
+	AuthorsRefs []AuthorRef `json:"authr,omitempty"`
 	Translators []string    `json:"transl,omitempty"`
-	Sequences   []string    `json:"seq,omitempty"`
+	Sequences   BookSeries  `json:"seq,omitempty"`
 	CoverID     string      `json:"cover,omitempty"`
 	Cover       *fb2.Binary `json:"-"`
 }
@@ -264,13 +385,14 @@ func NewBookMeta(data []fb2.TitleInfo, bin []fb2.Binary) (res BookMeta) {
 
 		if res.Annotation == "" {
 			for _, annot := range item.Annotation {
-				res.Annotation = annot.HTML
+				res.Annotation, _ = SanitizeAnnotation(annot.HTML)
 				break
 			}
 		}
 
 		for _, v := range item.Author {
 			res.Authors = append(res.Authors, v.String())
+			res.AuthorsRefs = append(res.AuthorsRefs, NewAuthorRef(v))
 		}
 
 		for _, v := range item.Translator {
@@ -278,7 +400,9 @@ func NewBookMeta(data []fb2.TitleInfo, bin []fb2.Binary) (res BookMeta) {
 		}
 
 		for _, v := range item.Sequence {
-			res.Sequences = append(res.Sequences, v.String())
+			if serie := NewBookSerie(v); serie.Name != "" {
+				res.Sequences = append(res.Sequences, serie)
+			}
 		}
 
 		if res.Title == "" {
@@ -312,16 +436,37 @@ func NewBookMeta(data []fb2.TitleInfo, bin []fb2.Binary) (res BookMeta) {
 		}
 	}
 
+	res.Genres = NormalizeGenres(res.Genres)
+	res.SrcLang, _ = NormalizeLang(res.SrcLang)
+
+	if lang, _ := NormalizeLang(res.Lang); lang != res.Lang {
+		res.LangOrig, res.Lang = res.Lang, lang
+	}
+
 	return
 }
 
+func (m *BookMeta) refs() []AuthorRef {
+	if len(m.AuthorsRefs) > 0 {
+		return m.AuthorsRefs
+	}
+
+	res := make([]AuthorRef, 0, len(m.Authors))
+	for _, name := range m.Authors {
+		res = append(res, NewAuthorRefFromName(name))
+	}
+
+	return res
+}
+
 type BookPublisher struct {
-	Title     string   `json:"title,omitempty"`
-	Publisher string   `json:"publ,omitempty"`
-	Year      string   `json:"year,omitempty"`
-	ISBN      string   `json:"isbn,omitempty"`
-	Authors   []string `json:"auth,omitempty"`
-	Sequences []string `json:"seqs,omitempty"`
+	Title     string     `json:"title,omitempty"`
+	Publisher string     `json:"publ,omitempty"`
+	Year      string     `json:"year,omitempty"`
+	ISBN      string     `json:"isbn,omitempty"`
+	ISBNs     []string   `json:"isbns,omitempty"`
+	Authors   []string   `json:"auth,omitempty"`
+	Sequences BookSeries `json:"seqs,omitempty"`
 }
 
 func NewBookPublisher(data fb2.Publisher) (res BookPublisher) {
@@ -332,7 +477,14 @@ func NewBookPublisher(data fb2.Publisher) (res BookPublisher) {
 	res.Authors = append(res.Authors, data.BookAuthor...)
 
 	for _, v := range data.Sequence {
-		res.Sequences = append(res.Sequences, v.String())
+		if serie := NewBookSerie(v); serie.Name != "" {
+			res.Sequences = append(res.Sequences, serie)
+		}
+	}
+
+	isbns, _ := ParseISBNs(res.ISBN)
+	for _, isbn := range isbns {
+		res.ISBNs = append(res.ISBNs, isbn.ISBN13)
 	}
 
 	return
@@ -347,30 +499,40 @@ const (
 	IdxFISBN       IndexField = "isbn"
 	IdxFTitle      IndexField = "title"
 	IdxFAuthor     IndexField = "auth"
+	IdxFAuthorKey  IndexField = "authk"
 	IdxFTranslator IndexField = "transl"
 	IdxFSerie      IndexField = "seq"
+	IdxFSerieNum   IndexField = "seqn"
 	IdxFDate       IndexField = "date"
 	IdxFGenre      IndexField = "genre"
 	IdxFPublisher  IndexField = "publ"
 	IdxFLang       IndexField = "lng"
 	IdxFKeywords   IndexField = "kwds"
 	IdxFLib        IndexField = "lib"
+	IdxFDup        IndexField = "dup"
+	IdxFWork       IndexField = "work"
+	IdxFAnnotation IndexField = "annot"
 )
 
 type BookIndex struct {
-	ID         string `json:"id,omitempty"`
-	Year       uint16 `json:"year,omitempty"`
-	ISBN       string `json:"isbn,omitempty"`
-	Title      string `json:"title,omitempty"`
-	Author     string `json:"auth,omitempty"`
-	Translator string `json:"transl,omitempty"`
-	Serie      string `json:"seq,omitempty"`
-	Date       string `json:"date,omitempty"`
-	Genre      string `json:"genre,omitempty"`
-	Publisher  string `json:"publ,omitempty"`
-	Lang       string `json:"lng,omitempty"`
-	Keywords   string `json:"kwds,omitempty"`
-	Lib        string `json:"lib,omitempty"`
+	ID         string   `json:"id,omitempty"`
+	Year       uint16   `json:"year,omitempty"`
+	ISBN       []string `json:"isbn,omitempty"`
+	Title      string   `json:"title,omitempty"`
+	Author     string   `json:"auth,omitempty"`
+	AuthorKeys []string `json:"authk,omitempty"`
+	Translator string   `json:"transl,omitempty"`
+	Serie      string   `json:"seq,omitempty"`
+	SerieNum   uint16   `json:"seqn,omitempty"`
+	Date       string   `json:"date,omitempty"`
+	Genre      string   `json:"genre,omitempty"`
+	Publisher  string   `json:"publ,omitempty"`
+	Lang       string   `json:"lng,omitempty"`
+	Keywords   []string `json:"kwds,omitempty"`
+	Lib        string   `json:"lib,omitempty"`
+	Dup        bool     `json:"dup,omitempty"`
+	Work       string   `json:"work,omitempty"`
+	Annotation string   `json:"annot,omitempty"`
 }
 
 func NewBookIndexMapping() *mapping.IndexMappingImpl {
@@ -381,8 +543,30 @@ func NewBookIndexMapping() *mapping.IndexMappingImpl {
 	sortField.DocValues = false
 	books.AddFieldMappingsAt(string(IdxFID), sortField)
 
+	numField := bleve.NewNumericFieldMapping()
+	numField.IncludeInAll = false
+	books.AddFieldMappingsAt(string(IdxFSerieNum), numField)
+
+	boolField := bleve.NewBooleanFieldMapping()
+	boolField.IncludeInAll = false
+	books.AddFieldMappingsAt(string(IdxFDup), boolField)
+
+	keywordField := bleve.NewTextFieldMapping()
+	keywordField.Analyzer = keyword.Name
+	keywordField.IncludeInAll = false
+	books.AddFieldMappingsAt(string(IdxFAuthorKey), keywordField)
+	books.AddFieldMappingsAt(string(IdxFWork), keywordField)
+
+	isbnField := bleve.NewTextFieldMapping()
+	isbnField.Analyzer = keyword.Name
+	books.AddFieldMappingsAt(string(IdxFISBN), isbnField)
+	books.AddFieldMappingsAt(string(IdxFKeywords), isbnField)
+
+	annotField := bleve.NewTextFieldMapping()
+	annotField.IncludeInAll = false
+	books.AddFieldMappingsAt(string(IdxFAnnotation), annotField)
+
 	strField := bleve.NewTextFieldMapping()
-	books.AddFieldMappingsAt(string(IdxFISBN), strField)
 	books.AddFieldMappingsAt(string(IdxFTitle), strField)
 	books.AddFieldMappingsAt(string(IdxFAuthor), strField)
 	books.AddFieldMappingsAt(string(IdxFTranslator), strField)
@@ -391,7 +575,6 @@ func NewBookIndexMapping() *mapping.IndexMappingImpl {
 	books.AddFieldMappingsAt(string(IdxFGenre), strField)
 	books.AddFieldMappingsAt(string(IdxFPublisher), strField)
 	books.AddFieldMappingsAt(string(IdxFLang), strField)
-	books.AddFieldMappingsAt(string(IdxFKeywords), strField)
 	books.AddFieldMappingsAt(string(IdxFLib), strField)
 
 	mapping := bleve.NewIndexMapping()
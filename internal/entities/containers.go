@@ -0,0 +1,138 @@
+package entities
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/egnd/fb2lib/pkg/archive"
+)
+
+const (
+	BookContainerZip  = archive.KindZip
+	BookContainerGzip = "gz"
+)
+
+// BookContainer returns type of compressed single book file, e.g. book.fb2.zip, book.fbz or book.fb2.gz.
+func BookContainer(name string) string {
+	name = strings.ToLower(name)
+
+	switch {
+	case strings.HasSuffix(name, ".fb2.zip"), strings.HasSuffix(name, ".fbz"):
+		return BookContainerZip
+	case strings.HasSuffix(name, ".fb2.gz"):
+		return BookContainerGzip
+	default:
+		return ""
+	}
+}
+
+// Archive returns path of archive or compressed file with the book and its type, empty values are returned
+// for plain book files.
+func (b Book) Archive() (string, string) {
+	if archivePath, kind, _ := archive.Split(b.Src); archivePath != "" {
+		return archivePath, kind
+	}
+
+	if container := BookContainer(b.Src); container != "" {
+		return b.Src, container
+	}
+
+	return "", ""
+}
+
+// Locator returns locator of the book data at its archive.
+func (b Book) Locator() archive.Locator {
+	return archive.Locator{
+		Offset:         b.Offset,
+		Size:           b.Size,
+		SizeCompressed: b.SizeCompressed,
+		Method:         b.Method,
+		CRC32:          b.CRC32,
+	}
+}
+
+// OpenBook returns reader of the book file, which is unpacked from archive if needed.
+func (l Libraries) OpenBook(book *Book) (io.ReadCloser, error) {
+	if book.Src == "" {
+		return nil, fmt.Errorf("empty book src [%s]", book.ID)
+	}
+
+	lib, ok := l[book.Lib]
+	if !ok {
+		return nil, fmt.Errorf("undefined lib name %s", book.Lib)
+	}
+
+	archivePath, container := book.Archive()
+	if container == "" {
+		return os.Open(path.Join(lib.Dir, book.Src))
+	}
+
+	file, err := os.Open(path.Join(lib.Dir, archivePath))
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.ReadCloser
+
+	if container == BookContainerGzip {
+		reader, err = gzip.NewReader(file)
+	} else {
+		reader, err = extractArchiveItem(file, container, book.Locator())
+	}
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &ArchiveItemReader{ReadCloser: reader, Archive: file}, nil
+}
+
+func extractArchiveItem(file *os.File, kind string, loc archive.Locator) (io.ReadCloser, error) {
+	finfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := archive.New(kind, file, finfo.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	return reader.Extract(loc)
+}
+
+// ArchiveItemReader closes archive file together with the reader of its item.
+type ArchiveItemReader struct {
+	io.ReadCloser
+	Archive io.Closer
+}
+
+func (r *ArchiveItemReader) Close() error {
+	err := r.ReadCloser.Close()
+
+	if archiveErr := r.Archive.Close(); err == nil {
+		err = archiveErr
+	}
+
+	return err
+}
+
+// GzipSize returns uncompressed size of gzip file from its trailer, it is valid for files less than 4GiB.
+func GzipSize(file io.ReaderAt, size int64) (uint64, error) {
+	if size < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	buf := make([]byte, 4)
+	if _, err := file.ReadAt(buf, size-4); err != nil {
+		return 0, err
+	}
+
+	return uint64(binary.LittleEndian.Uint32(buf)), nil
+}
@@ -0,0 +1,388 @@
+package entities
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+var dedupeTitlePattern = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+const (
+	dedupeAuthorPrefix = 4
+	dedupeTitlePrefix  = 4
+)
+
+const (
+	DedupePreferCover   = "cover"
+	DedupePreferISBN    = "isbn"
+	DedupePreferBigger  = "bigger"
+	DedupePreferSmaller = "smaller"
+	DedupePreferNewer   = "newer"
+	DedupePreferOlder   = "older"
+	DedupePreferLib     = "lib:"
+	DedupePreferLang    = "lang:"
+)
+
+type DedupeRules struct {
+	Similarity float64
+	SizeRatio  float64
+	Prefer     []string
+}
+
+func NewDedupeRules(cfgKey string, cfg *viper.Viper) DedupeRules {
+	res := DedupeRules{
+		Similarity: cfg.GetFloat64(cfgKey + ".similarity"),
+		SizeRatio:  cfg.GetFloat64(cfgKey + ".size_ratio"),
+		Prefer:     cfg.GetStringSlice(cfgKey + ".prefer"),
+	}
+
+	if res.Similarity <= 0 || res.Similarity > 1 {
+		res.Similarity = 0.85
+	}
+
+	if res.SizeRatio < 0 || res.SizeRatio > 1 {
+		res.SizeRatio = 0
+	}
+
+	if len(res.Prefer) == 0 {
+		res.Prefer = []string{DedupePreferCover, DedupePreferISBN, DedupePreferNewer, DedupePreferBigger}
+	}
+
+	return res
+}
+
+// Less reports whether the first candidate is more preferable than the second one.
+// Revisions of the same fb2 document are ordered by document version first.
+func (r DedupeRules) Less(a, b *DupCandidate) bool {
+	if SameDocRevisions(a, b) {
+		if cmp := CompareDocVersions(a.DocVersion, b.DocVersion); cmp != 0 {
+			return cmp > 0
+		}
+	}
+
+	for _, rule := range r.Prefer {
+		var aVal, bVal bool
+
+		switch {
+		case rule == DedupePreferCover:
+			aVal, bVal = a.HasCover, b.HasCover
+		case rule == DedupePreferISBN:
+			aVal, bVal = a.HasISBN, b.HasISBN
+		case rule == DedupePreferBigger:
+			aVal, bVal = a.Size > b.Size, b.Size > a.Size
+		case rule == DedupePreferSmaller:
+			aVal, bVal = a.Size < b.Size, b.Size < a.Size
+		case rule == DedupePreferNewer:
+			aVal, bVal = a.Year > b.Year, b.Year > a.Year
+		case rule == DedupePreferOlder:
+			aVal, bVal = a.Year > 0 && (b.Year == 0 || a.Year < b.Year), b.Year > 0 && (a.Year == 0 || b.Year < a.Year)
+		case strings.HasPrefix(rule, DedupePreferLib):
+			aVal, bVal = a.Lib == rule[len(DedupePreferLib):], b.Lib == rule[len(DedupePreferLib):]
+		case strings.HasPrefix(rule, DedupePreferLang):
+			aVal, bVal = a.Lang == rule[len(DedupePreferLang):], b.Lang == rule[len(DedupePreferLang):]
+		}
+
+		if aVal != bVal {
+			return aVal
+		}
+	}
+
+	return a.ID < b.ID
+}
+
+type DupCandidate struct {
+	ID         string
+	Lib        string
+	Src        string
+	Title      string
+	Authors    []string
+	Lang       string
+	Size       uint64
+	Year       uint16
+	HasCover   bool
+	HasISBN    bool
+	DocID      string
+	DocVersion string
+	DupOf      string
+	Alternates []string
+}
+
+func NewDupCandidate(book *Book, resolveAuthor func(string) string) DupCandidate {
+	res := DupCandidate{
+		ID:         book.ID,
+		Lib:        book.Lib,
+		Src:        book.Src,
+		Title:      NormalizeDupTitle(book.Info.Title),
+		Lang:       book.Info.Lang,
+		Size:       book.Size,
+		Year:       ParseYear(book.Info.Date),
+		HasCover:   book.Info.CoverID != "",
+		HasISBN:    len(book.ISBNTerms()) > 0,
+		DupOf:      book.DupOf,
+		Alternates: book.Alternates,
+	}
+
+	if book.DocInfo != nil {
+		res.DocID, res.DocVersion = book.DocInfo.ID, book.DocInfo.Version
+	}
+
+	for _, publ := range book.PublInfo {
+		if year := ParseYear(publ.Year); year > res.Year {
+			res.Year = year
+		}
+	}
+
+	for _, ref := range book.AuthorsRefs() {
+		if key := resolveAuthor(ref.Key); key != "" && !SliceHasString(res.Authors, key) {
+			res.Authors = append(res.Authors, key)
+		}
+	}
+
+	sort.Strings(res.Authors)
+
+	return res
+}
+
+// SameDocRevisions reports whether candidates are revisions of the same fb2 document. Some programs generate the same
+// document id for different books, so titles and authors of revisions have to match too.
+func SameDocRevisions(a, b *DupCandidate) bool {
+	return a.DocID != "" && a.DocID == b.DocID && a.Title == b.Title && AuthorsKeysSimilar(a.Authors, b.Authors, 1)
+}
+
+// NormalizeDupTitle lowercases title and strips punctuation, so spelling variants are compared by letters only.
+func NormalizeDupTitle(title string) string {
+	title = strings.ReplaceAll(strings.ToLower(title), "ё", "е")
+
+	return strings.TrimSpace(dedupeTitlePattern.ReplaceAllString(title, " "))
+}
+
+// TitleSimilarity returns Dice coefficient of titles trigrams.
+func TitleSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+
+	return newTrigrams(a).similarity(newTrigrams(b))
+}
+
+// trigrams keeps counts of string trigrams, so they are built once for every compared string.
+type trigrams struct {
+	grams map[string]int
+	total int
+}
+
+func newTrigrams(str string) trigrams {
+	runes := []rune("  " + str + " ")
+	res := trigrams{grams: make(map[string]int, len(runes))}
+
+	for k := 0; k+3 <= len(runes); k++ {
+		res.grams[string(runes[k:k+3])]++
+		res.total++
+	}
+
+	return res
+}
+
+// similarity returns Dice coefficient of trigrams.
+func (t trigrams) similarity(other trigrams) float64 {
+	if t.total == 0 || other.total == 0 {
+		return 0
+	}
+
+	if len(t.grams) > len(other.grams) {
+		t, other = other, t
+	}
+
+	var common int
+	for gram, cnt := range t.grams {
+		if otherCnt := other.grams[gram]; otherCnt < cnt {
+			common += otherCnt
+		} else {
+			common += cnt
+		}
+	}
+
+	return 2 * float64(common) / float64(t.total+other.total)
+}
+
+type DupCluster struct {
+	Main DupCandidate
+	Dups []DupCandidate
+}
+
+func (c DupCluster) IDs() []string {
+	res := make([]string, 0, len(c.Dups))
+
+	for _, item := range c.Dups {
+		res = append(res, item.ID)
+	}
+
+	return res
+}
+
+// FindDuplicates groups candidates by authors last names prefixes and titles first words prefixes and clusters them
+// by authors and titles similarity and files sizes.
+func FindDuplicates(items []DupCandidate, rules DedupeRules) []DupCluster {
+	groups := map[string][]int{}
+	for k, item := range items {
+		if item.Title == "" {
+			continue
+		}
+
+		if len(item.Authors) == 0 {
+			groups["title:"+item.Title] = append(groups["title:"+item.Title], k)
+			continue
+		}
+
+		// prefixes keep misspelled names and titles endings in the same group
+		title, _, _ := strings.Cut(item.Title, " ")
+		if runes := []rune(title); len(runes) > dedupeTitlePrefix {
+			title = string(runes[:dedupeTitlePrefix])
+		}
+
+		for _, author := range item.Authors {
+			last, _, _ := strings.Cut(author, "-")
+			if runes := []rune(last); len(runes) > dedupeAuthorPrefix {
+				last = string(runes[:dedupeAuthorPrefix])
+			}
+
+			key := "author:" + last + ":" + title
+			if last := len(groups[key]) - 1; last < 0 || groups[key][last] != k {
+				groups[key] = append(groups[key], k)
+			}
+		}
+	}
+
+	grams := make([]trigrams, len(items))
+	for _, group := range groups {
+		for _, k := range group {
+			if grams[k].grams == nil {
+				grams[k] = newTrigrams(items[k].Title)
+			}
+		}
+	}
+
+	parents := make([]int, len(items))
+	for k := range parents {
+		parents[k] = k
+	}
+
+	var find func(int) int
+	find = func(k int) int {
+		if parents[k] != k {
+			parents[k] = find(parents[k])
+		}
+
+		return parents[k]
+	}
+
+	for _, group := range groups {
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				a, b := &items[group[i]], &items[group[j]]
+				if find(group[i]) != find(group[j]) && rules.similarAuthors(a, b) &&
+					rules.similar(a, b, grams[group[i]], grams[group[j]]) {
+					parents[find(group[i])] = find(group[j])
+				}
+			}
+		}
+	}
+
+	clusters := map[int][]DupCandidate{}
+	for k, item := range items {
+		if item.Title != "" {
+			clusters[find(k)] = append(clusters[find(k)], item)
+		}
+	}
+
+	var res []DupCluster
+
+	for _, cluster := range clusters {
+		if len(cluster) < 2 {
+			continue
+		}
+
+		sort.Slice(cluster, func(i, j int) bool {
+			return rules.Less(&cluster[i], &cluster[j])
+		})
+
+		res = append(res, DupCluster{Main: cluster[0], Dups: cluster[1:]})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Main.ID < res[j].Main.ID
+	})
+
+	return res
+}
+
+func (r DedupeRules) similarAuthors(a, b *DupCandidate) bool {
+	return AuthorsKeysSimilar(a.Authors, b.Authors, r.Similarity)
+}
+
+// AuthorsKeysSimilar reports whether every author of the shorter list has a similar author at the other list, so
+// misspelled names or names with initials are treated as the same author.
+func AuthorsKeysSimilar(a, b []string, similarity float64) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+
+	if len(a) == 0 {
+		return len(b) == 0
+	}
+
+	for _, aKey := range a {
+		var found bool
+
+		for _, bKey := range b {
+			if found = AuthorKeysSimilar(aKey, bKey, similarity); found {
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AuthorKeysSimilar compares authors keys: keys with the same last name match if one first name is an initial or
+// a prefix of the other one, other keys are compared by trigrams similarity.
+func AuthorKeysSimilar(a, b string, similarity float64) bool {
+	if a == b {
+		return true
+	}
+
+	aLast, aFirst, _ := strings.Cut(a, "-")
+	bLast, bFirst, _ := strings.Cut(b, "-")
+
+	if aLast == bLast && (strings.HasPrefix(aFirst, bFirst) || strings.HasPrefix(bFirst, aFirst)) {
+		return true
+	}
+
+	return TitleSimilarity(a, b) >= similarity
+}
+
+func (r DedupeRules) similar(a, b *DupCandidate, aGrams, bGrams trigrams) bool {
+	if SameDocRevisions(a, b) {
+		return true
+	}
+
+	if a.Size > 0 && b.Size > 0 && r.SizeRatio > 0 {
+		minSize, maxSize := a.Size, b.Size
+		if minSize > maxSize {
+			minSize, maxSize = maxSize, minSize
+		}
+
+		if float64(minSize)/float64(maxSize) < r.SizeRatio {
+			return false
+		}
+	}
+
+	return a.Title == b.Title || aGrams.similarity(bGrams) >= r.Similarity
+}
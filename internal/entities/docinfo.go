@@ -0,0 +1,89 @@
+package entities
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/egnd/go-xmlparse"
+	"github.com/egnd/go-xmlparse/fb2"
+)
+
+type BookDocInfo struct {
+	ID         string            `json:"id,omitempty"`
+	Version    string            `json:"ver,omitempty"`
+	SrcURL     []string          `json:"srcurl,omitempty"`
+	Program    string            `json:"prog,omitempty"`
+	Date       string            `json:"date,omitempty"`
+	Authors    []string          `json:"auth,omitempty"`
+	Publishers []string          `json:"publ,omitempty"`
+	Custom     map[string]string `json:"custom,omitempty"`
+}
+
+func NewBookDocInfo(data []fb2.DocInfo, custom []fb2.CustomInfo) *BookDocInfo {
+	var res BookDocInfo
+
+	for _, item := range data {
+		if res.ID == "" {
+			res.ID = strings.TrimSpace(xmlparse.GetStrFrom(item.ID))
+		}
+
+		if res.Version == "" {
+			res.Version = strings.TrimSpace(xmlparse.GetStrFrom(item.Version))
+		}
+
+		res.SrcURL = append(res.SrcURL, item.SrcURL...)
+
+		for _, v := range item.Authors {
+			res.Authors = append(res.Authors, v.String())
+		}
+
+		for _, v := range item.Publishers {
+			res.Publishers = append(res.Publishers, v.String())
+		}
+	}
+
+	for _, item := range custom {
+		if item.InfoType == "" || strings.TrimSpace(item.Data) == "" {
+			continue
+		}
+
+		if res.Custom == nil {
+			res.Custom = map[string]string{}
+		}
+
+		res.Custom[item.InfoType] = strings.TrimSpace(item.Data)
+	}
+
+	if res.ID == "" && res.Version == "" && len(res.SrcURL) == 0 && len(res.Authors) == 0 && len(res.Custom) == 0 {
+		return nil
+	}
+
+	return &res
+}
+
+// CompareDocVersions compares dotted document versions numerically, e.g. "1.10" is newer than "1.9".
+func CompareDocVersions(a, b string) int {
+	aParts := strings.Split(strings.TrimSpace(a), ".")
+	bParts := strings.Split(strings.TrimSpace(b), ".")
+
+	for k := 0; k < len(aParts) || k < len(bParts); k++ {
+		var aNum, bNum float64
+
+		if k < len(aParts) {
+			aNum, _ = strconv.ParseFloat(strings.TrimSpace(aParts[k]), 64)
+		}
+
+		if k < len(bParts) {
+			bNum, _ = strconv.ParseFloat(strings.TrimSpace(bParts[k]), 64)
+		}
+
+		switch {
+		case aNum > bNum:
+			return 1
+		case aNum < bNum:
+			return -1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,240 @@
+package entities
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+const (
+	EncodingUTF8 = "utf-8"
+
+	encodingSampleSize = 32 * 1024
+	encodingDeclLength = 256
+	// encodingFallback is used for non utf-8 data without cyrillic text and encoding declaration
+	encodingFallback = "windows-1252"
+	// encodingMinCyrillic is min share of cyrillic letters to treat the text as russian one
+	encodingMinCyrillic = 0.3
+	encodingFrequent    = "оеаинтсрвлкмдпу"
+	// encodingMinValidUTF8 is min share of valid sequences among non-ascii chars of utf-8 text with broken bytes,
+	// legacy encoded texts have almost no valid sequences
+	encodingMinValidUTF8 = 0.9
+)
+
+var (
+	xmlDeclPattern    = regexp.MustCompile(`^\s*<\?xml[^>]*\?>`)
+	xmlDeclEncPattern = regexp.MustCompile(`encoding\s*=\s*["']([^"']*)["']`)
+	cyrillicEncodings = []encoding.Encoding{charmap.Windows1251, charmap.KOI8R, charmap.CodePage866}
+	encodingBOMs      = [][]byte{{0xFE, 0xFF}, {0xFF, 0xFE}}
+	encodingBOMUTF8   = []byte{0xEF, 0xBB, 0xBF}
+)
+
+// DeclaredEncoding returns canonical name of the encoding from xml declaration of the data.
+func DeclaredEncoding(data []byte) string {
+	if len(data) > encodingDeclLength {
+		data = data[:encodingDeclLength]
+	}
+
+	decl := xmlDeclPattern.Find(bytes.TrimPrefix(data, encodingBOMUTF8))
+	if decl == nil {
+		return ""
+	}
+
+	matches := xmlDeclEncPattern.FindSubmatch(decl)
+	if matches == nil {
+		return ""
+	}
+
+	enc, err := htmlindex.Get(strings.TrimSpace(string(matches[1])))
+	if err != nil {
+		return ""
+	}
+
+	name, _ := htmlindex.Name(enc)
+
+	return name
+}
+
+// DetectEncoding returns encoding of the xml data. Declared encoding is used unless the data contradicts it,
+// cyrillic legacy encodings are told apart by letters frequencies.
+func DetectEncoding(data []byte) string {
+	declared := DeclaredEncoding(data)
+
+	for _, bom := range encodingBOMs {
+		if bytes.HasPrefix(data, bom) {
+			// utf-16 is decoded by xml decoder itself
+			return declared
+		}
+	}
+
+	if utf8.Valid(data) {
+		if declared != "" && isASCII(data) {
+			return declared
+		}
+
+		return EncodingUTF8
+	}
+
+	sample := data
+	if len(sample) > encodingSampleSize {
+		sample = sample[:encodingSampleSize]
+	}
+
+	// a few broken bytes don't make utf-8 text a legacy encoded one
+	if valid, invalid := countUTF8(sample); valid > 0 && float64(valid) >= encodingMinValidUTF8*float64(valid+invalid) {
+		return EncodingUTF8
+	}
+
+	res, resScore := "", 0
+	if declared != "" && declared != EncodingUTF8 {
+		if score, _, err := scoreEncoding(declared, sample); err == nil {
+			res, resScore = declared, score
+		}
+	}
+
+	for _, enc := range cyrillicEncodings {
+		name, _ := htmlindex.Name(enc)
+		if name == declared {
+			continue
+		}
+
+		if score, ratio, err := scoreEncoding(name, sample); err == nil && ratio >= encodingMinCyrillic && score > resScore {
+			res, resScore = name, score
+		}
+	}
+
+	if res == "" {
+		return encodingFallback
+	}
+
+	return res
+}
+
+// DecodeFB2 converts xml data to utf-8 and returns name of its source encoding.
+func DecodeFB2(data []byte) ([]byte, string, error) {
+	name := DetectEncoding(data)
+
+	switch {
+	case name == EncodingUTF8:
+		data = bytes.ToValidUTF8(bytes.TrimPrefix(data, encodingBOMUTF8), []byte(string(utf8.RuneError)))
+
+		return setDeclaredUTF8(data), name, nil
+	case strings.HasPrefix(name, "utf-16") || name == "":
+		return data, name, nil
+	}
+
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, name, err
+	}
+
+	res, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, name, err
+	}
+
+	return setDeclaredUTF8(res), name, nil
+}
+
+func setDeclaredUTF8(data []byte) []byte {
+	decl := xmlDeclPattern.Find(data)
+	if decl == nil || !xmlDeclEncPattern.Match(decl) {
+		return data
+	}
+
+	return append(xmlDeclEncPattern.ReplaceAll(decl, []byte(`encoding="utf-8"`)), data[len(decl):]...)
+}
+
+// scoreEncoding decodes the sample and scores it as russian text: lowercase letters inside of words are common
+// and capitals after lowercase letters or pseudographics are rare.
+func scoreEncoding(name string, sample []byte) (score int, ratio float64, err error) {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return
+	}
+
+	text, err := enc.NewDecoder().Bytes(sample)
+	if err != nil {
+		return
+	}
+
+	var letters, cyrillic int
+
+	prevLower := false
+
+	for _, r := range string(text) {
+		isLower := false
+
+		switch {
+		case unicode.Is(unicode.Cyrillic, r) && unicode.IsLower(r):
+			letters++
+			cyrillic++
+			score++
+			isLower = true
+
+			if strings.ContainsRune(encodingFrequent, r) {
+				score++
+			}
+		case unicode.Is(unicode.Cyrillic, r):
+			letters++
+			cyrillic++
+
+			if prevLower {
+				score -= 2
+			}
+		case unicode.IsLetter(r):
+			letters++
+		case r >= 0x2500 && r <= 0x259F, unicode.IsControl(r) && !unicode.IsSpace(r):
+			score -= 2
+		}
+
+		prevLower = isLower
+	}
+
+	if letters > 0 {
+		ratio = float64(cyrillic) / float64(letters)
+	}
+
+	return
+}
+
+// countUTF8 counts valid multibyte utf-8 chars and invalid bytes of the data, incomplete char at the end is skipped.
+func countUTF8(data []byte) (valid int, invalid int) {
+	for len(data) > 0 {
+		if data[0] < utf8.RuneSelf {
+			data = data[1:]
+			continue
+		}
+
+		if !utf8.FullRune(data) {
+			break
+		}
+
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size == 1 {
+			invalid++
+		} else {
+			valid++
+		}
+
+		data = data[size:]
+	}
+
+	return
+}
+
+func isASCII(data []byte) bool {
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,345 @@
+package entities
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/egnd/go-xmlparse/fb2"
+)
+
+const (
+	BookFormatFB2  = "fb2"
+	BookFormatEPUB = "epub"
+
+	epubContainerPath = "META-INF/container.xml"
+)
+
+var ErrEPUBNoPackage = errors.New("epub package document not found")
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type EPUBCreator struct {
+	ID     string `xml:"id,attr"`
+	Role   string `xml:"role,attr"`
+	FileAs string `xml:"file-as,attr"`
+	Name   string `xml:",chardata"`
+}
+
+type EPUBIdentifier struct {
+	ID     string `xml:"id,attr"`
+	Scheme string `xml:"scheme,attr"`
+	Value  string `xml:",chardata"`
+}
+
+type EPUBMeta struct {
+	ID       string `xml:"id,attr"`
+	Name     string `xml:"name,attr"`
+	Content  string `xml:"content,attr"`
+	Property string `xml:"property,attr"`
+	Refines  string `xml:"refines,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type EPUBItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+// EPUBPackage is OPF package document of epub.
+type EPUBPackage struct {
+	Version  string `xml:"version,attr"`
+	Metadata struct {
+		Titles       []string         `xml:"title"`
+		Creators     []EPUBCreator    `xml:"creator"`
+		Contributors []EPUBCreator    `xml:"contributor"`
+		Languages    []string         `xml:"language"`
+		Identifiers  []EPUBIdentifier `xml:"identifier"`
+		Descriptions []string         `xml:"description"`
+		Subjects     []string         `xml:"subject"`
+		Dates        []string         `xml:"date"`
+		Publishers   []string         `xml:"publisher"`
+		Meta         []EPUBMeta       `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest []EPUBItem `xml:"manifest>item"`
+}
+
+type EPUB struct {
+	Package EPUBPackage
+	Dir     string
+	files   *zip.Reader
+}
+
+func ParseEPUB(data io.ReaderAt, size int64) (*EPUB, error) {
+	files, err := zip.NewReader(data, size)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &EPUB{files: files}
+
+	var container epubContainer
+	if err = res.decode(epubContainerPath, &container); err != nil {
+		return nil, err
+	}
+
+	for _, item := range container.Rootfiles {
+		if item.MediaType != "" && item.MediaType != "application/oebps-package+xml" {
+			continue
+		}
+
+		if err = res.decode(item.FullPath, &res.Package); err != nil {
+			return nil, err
+		}
+
+		res.Dir = path.Dir(item.FullPath)
+
+		return res, nil
+	}
+
+	return nil, ErrEPUBNoPackage
+}
+
+func (e *EPUB) decode(name string, obj interface{}) error {
+	file, err := e.files.Open(name)
+	if err != nil {
+		return fmt.Errorf("epub %s error: %w", name, err)
+	}
+	defer file.Close()
+
+	decoder := xml.NewDecoder(file)
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	return decoder.Decode(obj)
+}
+
+// ReadFile returns content of epub file by its path from epub root.
+func (e *EPUB) ReadFile(name string) ([]byte, error) {
+	file, err := e.files.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+func (e *EPUB) itemPath(item EPUBItem) string {
+	href, err := url.PathUnescape(item.Href)
+	if err != nil {
+		href = item.Href
+	}
+
+	return path.Join(e.Dir, href)
+}
+
+// Cover returns path and media type of the cover image.
+func (e *EPUB) Cover() (string, string) {
+	var coverID string
+	for _, meta := range e.Package.Metadata.Meta {
+		if meta.Name == "cover" {
+			coverID = meta.Content
+			break
+		}
+	}
+
+	for _, item := range e.Package.Manifest {
+		if SliceHasString(strings.Fields(item.Properties), "cover-image") || (coverID != "" && item.ID == coverID) {
+			return e.itemPath(item), item.MediaType
+		}
+	}
+
+	for _, item := range e.Package.Manifest {
+		if strings.HasPrefix(item.MediaType, "image/") && strings.Contains(strings.ToLower(item.ID+item.Href), "cover") {
+			return e.itemPath(item), item.MediaType
+		}
+	}
+
+	return "", ""
+}
+
+// refines returns epub3 refinements of the metadata item by its id.
+func (e *EPUB) refines(itemID, property string) string {
+	if itemID == "" {
+		return ""
+	}
+
+	for _, meta := range e.Package.Metadata.Meta {
+		if meta.Refines == "#"+itemID && meta.Property == property {
+			return strings.TrimSpace(meta.Value)
+		}
+	}
+
+	return ""
+}
+
+func (e *EPUB) creatorRole(item EPUBCreator) string {
+	if item.Role != "" {
+		return item.Role
+	}
+
+	return e.refines(item.ID, "role")
+}
+
+func (e *EPUB) creatorAuthor(item EPUBCreator) fb2.Author {
+	fileAs := item.FileAs
+	if fileAs == "" {
+		fileAs = e.refines(item.ID, "file-as")
+	}
+
+	var first, last string
+
+	if parts := strings.SplitN(fileAs, ",", 2); len(parts) == 2 {
+		last, first = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	} else {
+		parts := strings.Fields(item.Name)
+		switch len(parts) {
+		case 0:
+		case 1:
+			last = parts[0]
+		default:
+			first, last = strings.Join(parts[:len(parts)-1], " "), parts[len(parts)-1]
+		}
+	}
+
+	return fb2.Author{FirstName: []string{first}, LastName: []string{last}}
+}
+
+func (e *EPUB) series() (res []fb2.Sequence) {
+	var calibre fb2.Sequence
+
+	for _, meta := range e.Package.Metadata.Meta {
+		switch {
+		case meta.Name == "calibre:series":
+			calibre.Name = strings.TrimSpace(meta.Content)
+		case meta.Name == "calibre:series_index":
+			calibre.Number = strings.Split(strings.TrimSpace(meta.Content), ".")[0]
+		case meta.Property == "belongs-to-collection" && meta.Refines == "":
+			res = append(res, fb2.Sequence{
+				Name:   strings.TrimSpace(meta.Value),
+				Number: strings.Split(e.refines(meta.ID, "group-position"), ".")[0],
+			})
+		}
+	}
+
+	if calibre.Name != "" {
+		res = append(res, calibre)
+	}
+
+	return
+}
+
+func (e *EPUB) isbns() (res []string) {
+	for _, item := range e.Package.Metadata.Identifiers {
+		val := strings.TrimSpace(item.Value)
+		lower := strings.ToLower(val)
+
+		switch {
+		case strings.HasPrefix(lower, "urn:isbn:"):
+			res = append(res, val[len("urn:isbn:"):])
+		case strings.EqualFold(item.Scheme, "isbn"):
+			res = append(res, val)
+		default:
+			if _, err := ParseISBN(val); err == nil {
+				res = append(res, val)
+			}
+		}
+	}
+
+	return
+}
+
+// ToFB2 converts epub metadata to fb2 description, so epub books are handled as fb2 ones.
+func (e *EPUB) ToFB2() fb2.File {
+	meta := e.Package.Metadata
+
+	var info fb2.TitleInfo
+
+	info.BookTitle = meta.Titles
+	info.Lang = meta.Languages
+	info.Sequence = e.series()
+
+	for _, date := range meta.Dates {
+		info.Date = append(info.Date, strings.SplitN(strings.TrimSpace(date), "T", 2)[0])
+	}
+
+	for k, item := range append(meta.Creators, meta.Contributors...) {
+		if strings.TrimSpace(item.Name) == "" {
+			continue
+		}
+
+		switch role := e.creatorRole(item); {
+		case role == "trl":
+			info.Translator = append(info.Translator, e.creatorAuthor(item))
+		case role == "aut" || (role == "" && k < len(meta.Creators)):
+			info.Author = append(info.Author, e.creatorAuthor(item))
+		}
+	}
+
+	var keywords []string
+	for _, subject := range meta.Subjects {
+		if code, ok := NormalizeGenre(strings.TrimSpace(subject)); ok {
+			info.Genre = append(info.Genre, code)
+		} else {
+			keywords = append(keywords, subject)
+		}
+	}
+
+	if len(keywords) > 0 {
+		info.Keywords = []string{strings.Join(keywords, ", ")}
+	}
+
+	for _, descr := range meta.Descriptions {
+		info.Annotation = append(info.Annotation, fb2.Annotation{HTML: descr})
+	}
+
+	if cover, _ := e.Cover(); cover != "" {
+		info.Coverpage = []fb2.Cover{{Images: []fb2.Image{{Href: cover}}}}
+	}
+
+	descr := fb2.Description{TitleInfo: []fb2.TitleInfo{info}}
+
+	if isbns := e.isbns(); len(meta.Publishers) > 0 || len(isbns) > 0 {
+		publ := fb2.Publisher{Publisher: meta.Publishers}
+
+		if len(isbns) > 0 {
+			publ.ISBN = []string{strings.Join(isbns, ", ")}
+		}
+
+		if year := ParseYear(strings.Join(meta.Dates, " ")); year > 0 {
+			publ.Year = []string{fmt.Sprint(year)}
+		}
+
+		descr.PublishInfo = append(descr.PublishInfo, publ)
+	}
+
+	return fb2.File{Description: []fb2.Description{descr}}
+}
+
+func (b *Book) ReadEPUB(data *EPUB) {
+	fb2File := data.ToFB2()
+
+	b.ReadFB2(&fb2File)
+}
+
+// Format returns book file format by its source path.
+func (b Book) Format() string {
+	if strings.EqualFold(path.Ext(b.Src), "."+BookFormatEPUB) {
+		return BookFormatEPUB
+	}
+
+	return BookFormatFB2
+}
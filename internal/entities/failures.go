@@ -0,0 +1,72 @@
+package entities
+
+import (
+	"strings"
+	"time"
+
+	"github.com/egnd/fb2lib/pkg/archive"
+)
+
+const (
+	FailureStageDefine = "define"
+	FailureStageRead   = "read"
+	FailureStageParse  = "parse"
+	FailureStageSkip   = "skip"
+)
+
+// IndexFailure is a library item, which was not indexed.
+type IndexFailure struct {
+	Lib     string          `json:"lib"`
+	Src     string          `json:"src"`
+	Stage   string          `json:"stage"`
+	Error   string          `json:"err"`
+	TS      time.Time       `json:"ts"`
+	Locator archive.Locator `json:"loc"`
+}
+
+func NewIndexFailure(book Book, stage string, err error) IndexFailure {
+	return IndexFailure{
+		Lib:     book.Lib,
+		Src:     book.Src,
+		Stage:   stage,
+		Error:   err.Error(),
+		TS:      time.Now(),
+		Locator: book.Locator(),
+	}
+}
+
+// Book returns book with the source and locator of the failed item.
+func (f IndexFailure) Book() Book {
+	return Book{
+		Lib:            f.Lib,
+		Src:            f.Src,
+		Offset:         f.Locator.Offset,
+		Size:           f.Locator.Size,
+		SizeCompressed: f.Locator.SizeCompressed,
+		Method:         f.Locator.Method,
+		CRC32:          f.Locator.CRC32,
+	}
+}
+
+type IndexFailuresFilter struct {
+	Lib   string
+	Stage string
+	Query string
+}
+
+func (f IndexFailuresFilter) Match(item IndexFailure) bool {
+	if f.Lib != "" && f.Lib != item.Lib {
+		return false
+	}
+
+	if f.Stage != "" && f.Stage != item.Stage {
+		return false
+	}
+
+	if query := strings.ToLower(f.Query); query != "" &&
+		!strings.Contains(strings.ToLower(item.Src), query) && !strings.Contains(strings.ToLower(item.Error), query) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,510 @@
+package entities
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	GenreLangRu      = "ru"
+	GenreLangEn      = "en"
+	GenreUnknownCode = "other"
+)
+
+type Genre struct {
+	Code   string
+	Parent string
+	Titles map[string]string
+}
+
+func (g *Genre) Title(lang string) string {
+	if title, ok := g.Titles[lang]; ok {
+		return title
+	}
+
+	if title, ok := g.Titles[GenreLangRu]; ok {
+		return title
+	}
+
+	return g.Code
+}
+
+type genreDef struct {
+	code string
+	ru   string
+	en   string
+}
+
+type genreCategoryDef struct {
+	genreDef
+	genres []genreDef
+}
+
+// http://www.fictionbook.org/index.php/Жанры_FictionBook_2.1
+var fb2GenresTable = []genreCategoryDef{
+	{genreDef{"sf", "Фантастика", "Science fiction"}, []genreDef{
+		{"sf_history", "Альтернативная история", "Alternative history"},
+		{"sf_action", "Боевая фантастика", "Action science fiction"},
+		{"sf_epic", "Эпическая фантастика", "Epic science fiction"},
+		{"sf_heroic", "Героическая фантастика", "Heroic science fiction"},
+		{"sf_detective", "Детективная фантастика", "Detective science fiction"},
+		{"sf_cyberpunk", "Киберпанк", "Cyberpunk"},
+		{"sf_space", "Космическая фантастика", "Space science fiction"},
+		{"sf_social", "Социально-психологическая фантастика", "Social science fiction"},
+		{"sf_horror", "Ужасы и мистика", "Horror and mystic"},
+		{"sf_humor", "Юмористическая фантастика", "Humor science fiction"},
+		{"sf_fantasy", "Фэнтези", "Fantasy"},
+		{"sf_fantasy_city", "Городское фэнтези", "Urban fantasy"},
+		{"sf_postapocalyptic", "Постапокалипсис", "Post-apocalyptic"},
+		{"sf_etc", "Фантастика: прочее", "Science fiction: other"},
+		{"sf", "Научная фантастика", "Science fiction"},
+		{"popadanec", "Попаданцы", "Time travelers"},
+		{"hronoopera", "Хроноопера", "Chrono opera"},
+		{"sf_stimpank", "Стимпанк", "Steampunk"},
+		{"sf_mystic", "Мистика", "Mystic"},
+		{"sf_litrpg", "ЛитРПГ", "LitRPG"},
+	}},
+	{genreDef{"detective", "Детективы и триллеры", "Detectives and thrillers"}, []genreDef{
+		{"det_classic", "Классический детектив", "Classic detective"},
+		{"det_police", "Полицейский детектив", "Police procedural"},
+		{"det_action", "Боевик", "Action"},
+		{"det_irony", "Иронический детектив", "Ironic detective"},
+		{"det_history", "Исторический детектив", "Historical detective"},
+		{"det_espionage", "Шпионский детектив", "Espionage detective"},
+		{"det_crime", "Криминальный детектив", "Crime detective"},
+		{"det_political", "Политический детектив", "Political detective"},
+		{"det_maniac", "Маньяки", "Maniacs"},
+		{"det_hard", "Крутой детектив", "Hard-boiled"},
+		{"thriller", "Триллер", "Thriller"},
+		{"detective", "Детектив", "Detective"},
+	}},
+	{genreDef{"prose", "Проза", "Prose"}, []genreDef{
+		{"prose_classic", "Классическая проза", "Classic prose"},
+		{"prose_history", "Историческая проза", "Historical prose"},
+		{"prose_contemporary", "Современная проза", "Contemporary prose"},
+		{"prose_counter", "Контркультура", "Counterculture"},
+		{"prose_rus_classic", "Русская классическая проза", "Russian classic prose"},
+		{"prose_su_classics", "Советская классическая проза", "Soviet classic prose"},
+		{"prose_military", "Военная проза", "Military prose"},
+		{"prose_magic", "Магический реализм", "Magic realism"},
+		{"prose_abs", "Фантасмагория, абсурдистская проза", "Absurdist prose"},
+		{"prose_neformatny", "Экспериментальная проза", "Experimental prose"},
+		{"prose_epic", "Эпопея", "Epic"},
+		{"prose", "Проза", "Prose"},
+	}},
+	{genreDef{"love", "Любовные романы", "Romance"}, []genreDef{
+		{"love_contemporary", "Современные любовные романы", "Contemporary romance"},
+		{"love_history", "Исторические любовные романы", "Historical romance"},
+		{"love_detective", "Остросюжетные любовные романы", "Detective romance"},
+		{"love_short", "Короткие любовные романы", "Short romance"},
+		{"love_erotica", "Эротика", "Erotica"},
+		{"love_sf", "Любовное фэнтези", "Romantic fantasy"},
+		{"love", "Любовные романы", "Romance"},
+	}},
+	{genreDef{"adventure", "Приключения", "Adventure"}, []genreDef{
+		{"adv_western", "Вестерн", "Western"},
+		{"adv_history", "Исторические приключения", "Historical adventure"},
+		{"adv_indian", "Приключения про индейцев", "Indians"},
+		{"adv_maritime", "Морские приключения", "Maritime adventure"},
+		{"adv_geo", "Путешествия и география", "Travel and geography"},
+		{"adv_animal", "Природа и животные", "Nature and animals"},
+		{"adventure", "Приключения: прочее", "Adventure: other"},
+	}},
+	{genreDef{"children", "Детское", "Children"}, []genreDef{
+		{"child_tale", "Сказка", "Fairy tales"},
+		{"child_verse", "Детские стихи", "Children's verses"},
+		{"child_prose", "Детская проза", "Children's prose"},
+		{"child_sf", "Детская фантастика", "Children's science fiction"},
+		{"child_det", "Детские остросюжетные", "Children's action"},
+		{"child_adv", "Детские приключения", "Children's adventure"},
+		{"child_education", "Детская образовательная литература", "Children's education"},
+		{"children", "Детское: прочее", "Children: other"},
+	}},
+	{genreDef{"poetry", "Поэзия и драматургия", "Poetry and dramaturgy"}, []genreDef{
+		{"poetry", "Поэзия", "Poetry"},
+		{"dramaturgy", "Драматургия", "Dramaturgy"},
+		{"humor_verse", "Юмористические стихи", "Humor verses"},
+	}},
+	{genreDef{"antique", "Старинное", "Antique"}, []genreDef{
+		{"antique_ant", "Античная литература", "Antique literature"},
+		{"antique_european", "Европейская старинная литература", "European antique literature"},
+		{"antique_russian", "Древнерусская литература", "Old Russian literature"},
+		{"antique_east", "Древневосточная литература", "Old Eastern literature"},
+		{"antique_myths", "Мифы. Легенды. Эпос", "Myths, legends, epics"},
+		{"antique", "Старинное: прочее", "Antique: other"},
+	}},
+	{genreDef{"science", "Наука и образование", "Science and education"}, []genreDef{
+		{"sci_history", "История", "History"},
+		{"sci_psychology", "Психология", "Psychology"},
+		{"sci_culture", "Культурология", "Cultural studies"},
+		{"sci_religion", "Религиоведение", "Religious studies"},
+		{"sci_philosophy", "Философия", "Philosophy"},
+		{"sci_politics", "Политика", "Politics"},
+		{"sci_business", "Деловая литература", "Business literature"},
+		{"sci_juris", "Юриспруденция", "Jurisprudence"},
+		{"sci_linguistic", "Языкознание", "Linguistics"},
+		{"sci_medicine", "Медицина", "Medicine"},
+		{"sci_phys", "Физика", "Physics"},
+		{"sci_math", "Математика", "Mathematics"},
+		{"sci_chem", "Химия", "Chemistry"},
+		{"sci_biology", "Биология", "Biology"},
+		{"sci_tech", "Технические науки", "Technical sciences"},
+		{"sci_economy", "Экономика", "Economics"},
+		{"sci_state", "Государство и право", "State and law"},
+		{"sci_social_studies", "Обществознание", "Social studies"},
+		{"sci_pedagogy", "Педагогика", "Pedagogy"},
+		{"sci_geo", "Геология и география", "Geology and geography"},
+		{"sci_cosmos", "Астрономия и космос", "Astronomy and space"},
+		{"sci_ecology", "Экология", "Ecology"},
+		{"military_history", "Военная история", "Military history"},
+		{"science", "Научная литература: прочее", "Science: other"},
+	}},
+	{genreDef{"computers", "Компьютеры и интернет", "Computers and internet"}, []genreDef{
+		{"comp_www", "Интернет", "Internet"},
+		{"comp_programming", "Программирование", "Programming"},
+		{"comp_hard", "Компьютерное железо", "Hardware"},
+		{"comp_soft", "Программы", "Software"},
+		{"comp_db", "Базы данных", "Databases"},
+		{"comp_osnet", "ОС и сети", "OS and networking"},
+		{"computers", "Компьютеры: прочее", "Computers: other"},
+	}},
+	{genreDef{"reference", "Справочная литература", "Reference"}, []genreDef{
+		{"ref_encyc", "Энциклопедии", "Encyclopedias"},
+		{"ref_dict", "Словари", "Dictionaries"},
+		{"ref_ref", "Справочники", "Reference books"},
+		{"ref_guide", "Руководства", "Guides"},
+		{"reference", "Справочная литература: прочее", "Reference: other"},
+	}},
+	{genreDef{"nonfiction", "Документальная литература", "Nonfiction"}, []genreDef{
+		{"nonf_biography", "Биографии и мемуары", "Biography and memoirs"},
+		{"nonf_publicism", "Публицистика", "Publicism"},
+		{"nonf_criticism", "Критика", "Criticism"},
+		{"design", "Искусство и дизайн", "Art and design"},
+		{"nonf_military", "Военная документалистика", "Military documentary"},
+		{"nonfiction", "Документальная литература: прочее", "Nonfiction: other"},
+	}},
+	{genreDef{"religion", "Религия и духовность", "Religion and spirituality"}, []genreDef{
+		{"religion_rel", "Религия", "Religion"},
+		{"religion_esoterics", "Эзотерика", "Esoterics"},
+		{"religion_self", "Самосовершенствование", "Self-improvement"},
+		{"religion", "Религия: прочее", "Religion: other"},
+	}},
+	{genreDef{"humor", "Юмор", "Humor"}, []genreDef{
+		{"humor_anecdote", "Анекдоты", "Anecdotes"},
+		{"humor_prose", "Юмористическая проза", "Humor prose"},
+		{"humor", "Юмор: прочее", "Humor: other"},
+	}},
+	{genreDef{"home", "Дом и семья", "Home and family"}, []genreDef{
+		{"home_cooking", "Кулинария", "Cooking"},
+		{"home_pets", "Домашние животные", "Pets"},
+		{"home_crafts", "Хобби и ремесла", "Hobbies and crafts"},
+		{"home_entertain", "Развлечения", "Entertainment"},
+		{"home_health", "Здоровье", "Health"},
+		{"home_garden", "Сад и огород", "Garden"},
+		{"home_diy", "Сделай сам", "Do it yourself"},
+		{"home_sport", "Спорт", "Sports"},
+		{"home_sex", "Эротика, секс", "Sex"},
+		{"home", "Дом и семья: прочее", "Home: other"},
+	}},
+	{genreDef{"business", "Экономика и бизнес", "Business"}, []genreDef{
+		{"banking", "Банковское дело", "Banking"},
+		{"accounting", "Бухучет и аудит", "Accounting"},
+		{"marketing", "Маркетинг, PR, реклама", "Marketing"},
+		{"org_behavior", "Корпоративная культура", "Corporate culture"},
+		{"popular_business", "Карьера, кадры", "Career"},
+		{"real_estate", "Недвижимость", "Real estate"},
+		{"economics", "Экономика", "Economics"},
+		{"global_economy", "Внешнеэкономическая деятельность", "Global economy"},
+		{"stock", "Ценные бумаги, инвестиции", "Stocks and investments"},
+		{"small_business", "Малый бизнес", "Small business"},
+		{"management", "Управление, подбор персонала", "Management"},
+		{"personal_finance", "Личные финансы", "Personal finance"},
+		{"industries", "Отраслевые издания", "Industries"},
+		{"job_hunting", "Поиск работы, карьера", "Job hunting"},
+		{"trade", "Торговля", "Trade"},
+	}},
+	{genreDef{"other", "Прочее", "Other"}, []genreDef{
+		{"comics", "Комиксы", "Comics"},
+		{"periodic", "Журналы, газеты", "Periodicals"},
+		{"notes", "Партитуры", "Music notes"},
+		{"unfinished", "Недописанное", "Unfinished"},
+		{"network_literature", "Сетевая литература", "Network literature"},
+		{"other", "Неотсортированное", "Unsorted"},
+	}},
+}
+
+var fb2GenresAliases = map[string]string{
+	"fantasy":              "sf_fantasy",
+	"sf_fantasy_irony":     "sf_fantasy",
+	"fantasy_fight":        "sf_fantasy",
+	"dragon_fantasy":       "sf_fantasy",
+	"russian_fantasy":      "sf_fantasy",
+	"historical_fantasy":   "sf_fantasy",
+	"city_fantasy":         "sf_fantasy_city",
+	"sf_cyber_punk":        "sf_cyberpunk",
+	"sf_postapocalypse":    "sf_postapocalyptic",
+	"postapocalyptic":      "sf_postapocalyptic",
+	"science_fiction":      "sf",
+	"sci_fi":               "sf",
+	"sf_space_opera":       "sf_space",
+	"space_opera":          "sf_space",
+	"horror":               "sf_horror",
+	"mystic":               "sf_mystic",
+	"litrpg":               "sf_litrpg",
+	"det_cozy":             "det_irony",
+	"det_su":               "det_police",
+	"detective_classic":    "det_classic",
+	"thriller_legal":       "thriller",
+	"thriller_medical":     "thriller",
+	"thriller_techno":      "thriller",
+	"action":               "det_action",
+	"prose_rus_classics":   "prose_rus_classic",
+	"prose_su_classic":     "prose_su_classics",
+	"prose_classics":       "prose_classic",
+	"literature_classics":  "prose_classic",
+	"literature":           "prose",
+	"fiction":              "prose",
+	"romance":              "love",
+	"romance_sf":           "love_sf",
+	"love_fantasy":         "love_sf",
+	"adv_story":            "adventure",
+	"adv_modern":           "adventure",
+	"child_4":              "children",
+	"child_classical":      "child_prose",
+	"foreign_children":     "child_prose",
+	"poem":                 "poetry",
+	"verse":                "poetry",
+	"drama":                "dramaturgy",
+	"history":              "sci_history",
+	"psy_generic":          "sci_psychology",
+	"psychology":           "sci_psychology",
+	"philosophy":           "sci_philosophy",
+	"biography":            "nonf_biography",
+	"biogr":                "nonf_biography",
+	"memoirs":              "nonf_biography",
+	"publicism":            "nonf_publicism",
+	"cooking":              "home_cooking",
+	"health":               "home_health",
+	"sport":                "home_sport",
+	"humor_satire":         "humor",
+	"comp_dsp":             "computers",
+	"programming":          "comp_programming",
+	"foreign_sf":           "sf",
+	"foreign_fantasy":      "sf_fantasy",
+	"foreign_detective":    "detective",
+	"foreign_action":       "det_action",
+	"foreign_prose":        "prose_contemporary",
+	"foreign_contemporary": "prose_contemporary",
+	"foreign_love":         "love_contemporary",
+	"foreign_adventure":    "adventure",
+	"foreign_poetry":       "poetry",
+	"foreign_humor":        "humor",
+	"foreign_publicism":    "nonf_publicism",
+	"foreign_antique":      "antique",
+	"foreign_edu":          "science",
+	"foreign_psychology":   "sci_psychology",
+	"foreign_business":     "economics",
+	"foreign_comp":         "computers",
+	"foreign_home":         "home",
+	"foreign_language":     "sci_linguistic",
+	"foreign_other":        "other",
+	"unrecognised":         "other",
+	"unknown":              "other",
+}
+
+var fb2Genres = func() map[string]*Genre {
+	res := make(map[string]*Genre, 300)
+
+	for _, category := range fb2GenresTable {
+		res[category.code] = &Genre{Code: category.code, Titles: map[string]string{
+			GenreLangRu: category.ru, GenreLangEn: category.en,
+		}}
+	}
+
+	for _, category := range fb2GenresTable {
+		for _, item := range category.genres {
+			if item.code == category.code {
+				continue
+			}
+
+			res[item.code] = &Genre{Code: item.code, Parent: category.code, Titles: map[string]string{
+				GenreLangRu: item.ru, GenreLangEn: item.en,
+			}}
+		}
+	}
+
+	return res
+}()
+
+// NormalizeGenre maps non-standard genre code to the standard one, known flag is false for unknown codes.
+func NormalizeGenre(code string) (res string, known bool) {
+	res = strings.ToLower(strings.TrimSpace(code))
+
+	if alias, ok := fb2GenresAliases[res]; ok {
+		res = alias
+	}
+
+	_, known = fb2Genres[res]
+
+	return
+}
+
+func GetGenre(code string) *Genre {
+	code, _ = NormalizeGenre(code)
+
+	if genre, ok := fb2Genres[code]; ok {
+		return genre
+	}
+
+	return nil
+}
+
+func GetGenreTitle(code, lang string) string {
+	if genre := GetGenre(code); genre != nil {
+		return genre.Title(lang)
+	}
+
+	return code
+}
+
+type GenresTreeNode struct {
+	Code     string
+	Title    string
+	Freq     int
+	Children []GenresTreeNode
+}
+
+// NewGenresTree groups genres frequencies by parent categories. Categories counts are taken from cats, which count
+// books once per category, counts of genres are summed up for categories missing there.
+func NewGenresTree(genres FreqsItems, cats FreqsItems, lang string) []GenresTreeNode {
+	index := map[string]*GenresTreeNode{}
+	var order []string
+
+	getNode := func(code string) *GenresTreeNode {
+		if node, ok := index[code]; ok {
+			return node
+		}
+
+		index[code] = &GenresTreeNode{Code: code, Title: GetGenreTitle(code, lang)}
+		order = append(order, code)
+
+		return index[code]
+	}
+
+	catsFreqs := make(map[string]int, len(cats))
+	for _, item := range cats {
+		catsFreqs[item.Val] = item.Freq
+	}
+
+	for _, item := range genres {
+		code, _ := NormalizeGenre(item.Val)
+		parentCode := GenreCategory(code)
+
+		parent := getNode(parentCode)
+		if freq, ok := catsFreqs[parentCode]; ok {
+			parent.Freq = freq
+		} else {
+			parent.Freq += item.Freq
+		}
+
+		if code == parentCode {
+			continue
+		}
+
+		var found bool
+		for k := range parent.Children {
+			if parent.Children[k].Code == code {
+				parent.Children[k].Freq += item.Freq
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			parent.Children = append(parent.Children, GenresTreeNode{
+				Code: code, Title: GetGenreTitle(code, lang), Freq: item.Freq,
+			})
+		}
+	}
+
+	res := make([]GenresTreeNode, 0, len(order))
+	for _, code := range order {
+		node := *index[code]
+		sort.SliceStable(node.Children, func(i, j int) bool {
+			return node.Children[i].Freq > node.Children[j].Freq
+		})
+		res = append(res, node)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Freq > res[j].Freq
+	})
+
+	return res
+}
+
+func NormalizeGenres(codes []string) []string {
+	res := make([]string, 0, len(codes))
+
+	for _, code := range codes {
+		if code, _ = NormalizeGenre(code); code != "" && !SliceHasString(res, code) {
+			res = append(res, code)
+		}
+	}
+
+	return res
+}
+
+func UnknownGenres(codes []string) (res []string) {
+	for _, code := range codes {
+		if norm, known := NormalizeGenre(code); !known && norm != "" {
+			res = append(res, norm)
+		}
+	}
+
+	return
+}
+
+// GenreCategory returns code of the genre category, unknown genres are grouped to GenreUnknownCode.
+func GenreCategory(code string) string {
+	genre := GetGenre(code)
+
+	switch {
+	case genre == nil:
+		return GenreUnknownCode
+	case genre.Parent != "":
+		return genre.Parent
+	default:
+		return genre.Code
+	}
+}
+
+// GenresCategories returns unique categories of the genres.
+func GenresCategories(codes []string) []string {
+	res := make([]string, 0, len(codes))
+
+	for _, code := range codes {
+		if cat := GenreCategory(code); !SliceHasString(res, cat) {
+			res = append(res, cat)
+		}
+	}
+
+	return res
+}
+
+// WithParentGenres appends parent categories codes, so books can be found by category.
+func WithParentGenres(codes []string) []string {
+	res := make([]string, 0, len(codes)*2)
+
+	for _, code := range codes {
+		if !SliceHasString(res, code) {
+			res = append(res, code)
+		}
+
+		if genre := GetGenre(code); genre != nil && genre.Parent != "" && !SliceHasString(res, genre.Parent) {
+			res = append(res, genre.Parent)
+		}
+	}
+
+	return res
+}
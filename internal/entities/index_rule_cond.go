@@ -0,0 +1,399 @@
+package entities
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cast"
+)
+
+// IdxFSize is uncompressed size of the book file, it is checked by index rules only.
+const IdxFSize IndexField = "size"
+
+var (
+	ruleTextFields = []IndexField{
+		IdxFLang, IdxFGenre, IdxFISBN, IdxFAuthor, IdxFTranslator, IdxFSerie, IdxFDate, IdxFPublisher, IdxFTitle, IdxFLib,
+	}
+	ruleNumFields = []IndexField{IdxFYear, IdxFSize}
+	ruleTextOps   = []string{"exact", "prefix", "contains", "regex"}
+	ruleNumOps    = []string{"eq", "lt", "lte", "gt", "gte"}
+)
+
+// RuleFields returns fields, which could be checked by index rules.
+func RuleFields() []IndexField {
+	return append(append([]IndexField{}, ruleTextFields...), ruleNumFields...)
+}
+
+// RuleCond is a condition of index rules, which matches values of the book.
+type RuleCond interface {
+	Match(RuleValues) bool
+	String() string
+	fields() []IndexField
+}
+
+// ParseRuleCond parses the condition map, all its keys must match the book. Keys are combinations of conditions
+// or fields with their matchers:
+//   - all: list of conditions, which must all match
+//   - any: list of conditions, at least one of them must match
+//   - not: condition, which must not match
+//   - <field>: map of matchers, e.g. {regex: "^sf"} or {gte: 1900, lt: 2000}, or values, which are matched
+//     as substrings for text fields and as numbers for year and size
+//
+// Text matchers are exact, prefix, contains and regex, numeric ones are eq, lt, lte, gt and gte. Matcher with
+// several values matches if any of them matches any value of the field. Text is matched case-insensitively,
+// numeric matchers don't match unknown year, size could be set with units, e.g. 10MB.
+func ParseRuleCond(raw interface{}) (RuleCond, error) {
+	cfg, ok := toRuleMap(raw)
+	if !ok || len(cfg) == 0 {
+		return nil, fmt.Errorf("condition should be a map: %v", raw)
+	}
+
+	conds := make([]RuleCond, 0, len(cfg))
+
+	for _, key := range sortedRuleKeys(cfg) {
+		var cond RuleCond
+		var err error
+
+		switch key {
+		case "all", "any":
+			cond, err = parseRuleComb(key, cfg[key])
+		case "not":
+			if cond, err = ParseRuleCond(cfg[key]); err == nil {
+				cond = &ruleComb{op: key, conds: []RuleCond{cond}}
+			}
+		default:
+			cond, err = parseRuleField(IndexField(key), cfg[key])
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		conds = append(conds, cond)
+	}
+
+	return allRuleConds(conds), nil
+}
+
+func parseRuleComb(op string, raw interface{}) (RuleCond, error) {
+	items := toRuleList(raw)
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%s should be a list of conditions", op)
+	}
+
+	res := &ruleComb{op: op}
+
+	for _, item := range items {
+		cond, err := ParseRuleCond(item)
+		if err != nil {
+			return nil, err
+		}
+
+		res.conds = append(res.conds, cond)
+	}
+
+	return res, nil
+}
+
+func parseRuleField(field IndexField, raw interface{}) (RuleCond, error) {
+	if !isTextRuleField(field) && !isNumericRuleField(field) {
+		return nil, fmt.Errorf("invalid rule field %s", field)
+	}
+
+	matchers, ok := toRuleMap(raw)
+	if !ok {
+		if isNumericRuleField(field) {
+			return newRuleMatcher(field, "eq", raw)
+		}
+
+		return newRuleMatcher(field, "contains", raw)
+	}
+
+	if len(matchers) == 0 {
+		return nil, fmt.Errorf("empty matchers of %s field", field)
+	}
+
+	conds := make([]RuleCond, 0, len(matchers))
+
+	for _, op := range sortedRuleKeys(matchers) {
+		cond, err := newRuleMatcher(field, op, matchers[op])
+		if err != nil {
+			return nil, err
+		}
+
+		conds = append(conds, cond)
+	}
+
+	return allRuleConds(conds), nil
+}
+
+func allRuleConds(conds []RuleCond) RuleCond {
+	if len(conds) == 1 {
+		return conds[0]
+	}
+
+	return &ruleComb{op: "all", conds: conds}
+}
+
+type ruleComb struct {
+	op    string
+	conds []RuleCond
+}
+
+func (c *ruleComb) Match(values RuleValues) bool {
+	switch c.op {
+	case "any":
+		for _, cond := range c.conds {
+			if cond.Match(values) {
+				return true
+			}
+		}
+
+		return false
+	case "not":
+		return !c.conds[0].Match(values)
+	default:
+		for _, cond := range c.conds {
+			if !cond.Match(values) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+func (c *ruleComb) String() string {
+	items := make([]string, 0, len(c.conds))
+	for _, cond := range c.conds {
+		items = append(items, cond.String())
+	}
+
+	return fmt.Sprintf("%s(%s)", c.op, strings.Join(items, ", "))
+}
+
+func (c *ruleComb) fields() (res []IndexField) {
+	for _, cond := range c.conds {
+		for _, field := range cond.fields() {
+			if !hasRuleField(res, field) {
+				res = append(res, field)
+			}
+		}
+	}
+
+	return
+}
+
+type ruleMatcher struct {
+	field IndexField
+	op    string
+	strs  []string
+	nums  []float64
+	regs  []*regexp.Regexp
+}
+
+func newContainsMatcher(field IndexField, vals []string) *ruleMatcher {
+	return &ruleMatcher{field: field, op: "contains", strs: vals}
+}
+
+func newRuleMatcher(field IndexField, op string, raw interface{}) (*ruleMatcher, error) {
+	res := &ruleMatcher{field: field, op: op}
+	numeric := isNumericRuleField(field)
+
+	for _, item := range toRuleList(raw) {
+		switch {
+		case numeric && hasRuleOp(ruleNumOps, op):
+			num, err := parseRuleNumber(field, item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value %v: %w", field, item, err)
+			}
+
+			res.nums = append(res.nums, num)
+		case !numeric && op == "regex":
+			reg, err := regexp.Compile("(?i)" + cast.ToString(item))
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s regex: %w", field, err)
+			}
+
+			res.strs = append(res.strs, cast.ToString(item))
+			res.regs = append(res.regs, reg)
+		case !numeric && hasRuleOp(ruleTextOps, op):
+			if val := normalizeRuleValue(field, cast.ToString(item)); val != "" {
+				res.strs = append(res.strs, val)
+			}
+		default:
+			return nil, fmt.Errorf("invalid matcher %s of %s field", op, field)
+		}
+	}
+
+	if len(res.strs) == 0 && len(res.nums) == 0 {
+		return nil, fmt.Errorf("empty values of %s %s matcher", field, op)
+	}
+
+	return res, nil
+}
+
+func (m *ruleMatcher) Match(values RuleValues) bool {
+	if isNumericRuleField(m.field) {
+		return m.matchNumber(values.Number(m.field))
+	}
+
+	for _, val := range values.Strings(m.field) {
+		for k, pattern := range m.strs {
+			switch {
+			case m.op == "exact" && val == pattern,
+				m.op == "prefix" && strings.HasPrefix(val, pattern),
+				m.op == "contains" && strings.Contains(val, pattern),
+				m.op == "regex" && m.regs[k].MatchString(val):
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (m *ruleMatcher) matchNumber(val float64) bool {
+	if val == 0 {
+		return false
+	}
+
+	for _, num := range m.nums {
+		switch {
+		case m.op == "eq" && val == num,
+			m.op == "lt" && val < num,
+			m.op == "lte" && val <= num,
+			m.op == "gt" && val > num,
+			m.op == "gte" && val >= num:
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *ruleMatcher) String() string {
+	vals := make([]string, 0, len(m.strs)+len(m.nums))
+
+	for _, val := range m.strs {
+		vals = append(vals, strconv.Quote(val))
+	}
+
+	for _, val := range m.nums {
+		vals = append(vals, strconv.FormatFloat(val, 'f', -1, 64))
+	}
+
+	if len(vals) == 1 {
+		return fmt.Sprintf("%s %s %s", m.field, m.op, vals[0])
+	}
+
+	return fmt.Sprintf("%s %s [%s]", m.field, m.op, strings.Join(vals, ", "))
+}
+
+func (m *ruleMatcher) fields() []IndexField {
+	return []IndexField{m.field}
+}
+
+func normalizeRuleValue(field IndexField, val string) string {
+	val = strings.ToLower(strings.TrimSpace(val))
+	if val == "" {
+		return val
+	}
+
+	switch field {
+	case IdxFLang:
+		if lang, ok := NormalizeLang(val); ok {
+			val = lang
+		}
+	case IdxFISBN:
+		if isbn, err := ParseISBN(val); err == nil {
+			val = isbn.ISBN13
+		} else {
+			val = strings.ToLower(CleanISBN(val))
+		}
+	}
+
+	return val
+}
+
+func parseRuleNumber(field IndexField, raw interface{}) (float64, error) {
+	if str, ok := raw.(string); ok && field == IdxFSize {
+		size, err := humanize.ParseBytes(str)
+
+		return float64(size), err
+	}
+
+	return cast.ToFloat64E(raw)
+}
+
+// toRuleMap returns map of the config value with lowercased keys.
+func toRuleMap(raw interface{}) (map[string]interface{}, bool) {
+	switch raw.(type) {
+	case map[string]interface{}, map[interface{}]interface{}:
+	default:
+		return nil, false
+	}
+
+	res := map[string]interface{}{}
+	for key, val := range cast.ToStringMap(raw) {
+		res[strings.ToLower(key)] = val
+	}
+
+	return res, true
+}
+
+// toRuleList returns items of the config value, single value is returned as a list with one item.
+func toRuleList(raw interface{}) []interface{} {
+	switch items := raw.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return items
+	case []string:
+		res := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			res = append(res, item)
+		}
+
+		return res
+	default:
+		return []interface{}{raw}
+	}
+}
+
+func sortedRuleKeys(cfg map[string]interface{}) []string {
+	res := make([]string, 0, len(cfg))
+	for key := range cfg {
+		res = append(res, key)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+func hasRuleOp(ops []string, op string) bool {
+	return SliceHasString(ops, op)
+}
+
+func hasRuleField(fields []IndexField, field IndexField) bool {
+	for _, item := range fields {
+		if item == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isTextRuleField(field IndexField) bool {
+	return hasRuleField(ruleTextFields, field)
+}
+
+func isNumericRuleField(field IndexField) bool {
+	return hasRuleField(ruleNumFields, field)
+}
@@ -2,130 +2,343 @@ package entities
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 )
 
-type IndexRules map[string]map[string]struct{}
+const (
+	RuleOnly   = "only"
+	RuleExcept = "except"
+	RuleAccept = "accept"
+	RuleReject = "reject"
 
-func NewIndexRules(cfgKey string, cfg *viper.Viper) (res IndexRules, err error) {
-	var ruleType string
-	res = IndexRules{}
+	RuleSetGlobal = "global"
+)
 
-	for field, values := range cfg.GetStringMapStringSlice(cfgKey) {
-		for _, val := range values {
-			if ruleType = "only"; val[0:1] == "-" {
-				ruleType = "except"
-			}
+// IndexRules decides which books are indexed. Global rules are checked for all books, rules of the library are
+// checked for its books after them.
+//
+// Each rule set has short form lists of case-insensitive substrings by fields, values prefixed with "-" are
+// excepted, others are required. Accept conditions must all match the book and reject conditions must not match
+// it, conditions are described at ParseRuleCond.
+type IndexRules struct {
+	global *RuleSet
+	libs   map[string]*RuleSet
+}
 
-			if val = strings.TrimSpace(strings.ToLower(strings.TrimLeft(val, "+-"))); val == "" {
-				continue
-			}
+// ErrRuleMismatch is returned for books, which field value doesn't match the index rules.
+type ErrRuleMismatch struct {
+	Field IndexField
+	Value string
+	Rule  string
+}
 
-			if _, ok := res[field+"-"+ruleType]; !ok {
-				res[field+"-"+ruleType] = map[string]struct{}{}
-			}
+func (e *ErrRuleMismatch) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("rule %s", e.Rule)
+	}
 
-			res[field+"-"+ruleType][val] = struct{}{}
-		}
+	return fmt.Sprintf("rule %s: %s", e.Rule, e.Value)
+}
 
-		if len(res[field+"-only"]) > 0 && len(res[field+"-except"]) > 0 {
-			for k := range res[field+"-except"] {
-				delete(res[field+"-only"], k)
-			}
+// RuleResult explains the rule check of the book.
+type RuleResult struct {
+	Set     string
+	Kind    string
+	Rule    string
+	Cond    string
+	Field   IndexField
+	Value   string
+	Matched bool
+	Passed  bool
+}
+
+// NewIndexRules reads global rules by the key and rules of libraries.
+func NewIndexRules(cfgKey string, cfg *viper.Viper, libs Libraries) (res *IndexRules, err error) {
+	res = &IndexRules{libs: map[string]*RuleSet{}}
 
-			delete(res, field+"-except")
+	if res.global, err = NewRuleSet(RuleSetGlobal, cast.ToStringMap(cfg.Get(cfgKey))); err != nil {
+		return nil, err
+	}
+
+	for name, lib := range libs {
+		if len(lib.Rules) == 0 {
+			continue
+		}
+
+		if res.libs[name], err = NewRuleSet(name, lib.Rules); err != nil {
+			return nil, err
 		}
 	}
 
 	return
 }
 
-func (r IndexRules) Check(book *Book) error {
-	index := book.Index()
-	for _, field := range []IndexField{IdxFLang, IdxFGenre, IdxFISBN, IdxFAuthor, IdxFTranslator, IdxFSerie, IdxFDate, IdxFPublisher, IdxFTitle} {
-		if err := r.matchRules(field, &index); err != nil {
-			return err
+func (r *IndexRules) Check(book *Book) error {
+	if r == nil {
+		return nil
+	}
+
+	values := NewRuleValues(book)
+
+	for _, set := range r.sets(book.Lib) {
+		for _, rule := range set.rules {
+			if res := rule.check(set.name, values); !res.Passed {
+				return &ErrRuleMismatch{Field: res.Field, Value: res.Value, Rule: res.Rule}
+			}
 		}
 	}
 
 	return nil
 }
 
-func (r IndexRules) matchRules(field IndexField, index *BookIndex) (err error) {
-	var val string
+// Explain checks all rules for the book, the book is skipped if any result is not passed.
+func (r *IndexRules) Explain(book *Book) (res []RuleResult) {
+	if r == nil {
+		return
+	}
+
+	values := NewRuleValues(book)
 
-	switch field {
-	case IdxFLang:
-		if val = index.Lang; index.Lang == "" {
-			val = "ru"
+	for _, set := range r.sets(book.Lib) {
+		for _, rule := range set.rules {
+			res = append(res, rule.check(set.name, values))
 		}
-	case IdxFGenre:
-		val = index.Genre
-	case IdxFISBN:
-		val = index.ISBN
-	case IdxFAuthor:
-		val = index.Author
-	case IdxFTranslator:
-		val = index.Translator
-	case IdxFSerie:
-		val = index.Serie
-	case IdxFDate:
-		val = index.Date
-	case IdxFPublisher:
-		val = index.Publisher
-	case IdxFTitle:
-		val = index.Title
-	default:
-		return fmt.Errorf("invalid rule field type - %v", field)
 	}
 
-	val = strings.ToLower(strings.TrimSpace(val))
+	return
+}
+
+func (r *IndexRules) sets(libName string) []*RuleSet {
+	res := []*RuleSet{r.global}
 
-	if !r.checkOnly(field, val) {
-		return fmt.Errorf("rule %s: %s", field, val)
+	if lib, ok := r.libs[libName]; ok {
+		res = append(res, lib)
 	}
 
-	if !r.checkExcept(field, val) {
-		err = fmt.Errorf("rule %s: %s", field, val)
+	return res
+}
+
+// RuleSet is a list of rules read from the config map.
+type RuleSet struct {
+	name  string
+	rules []indexRule
+}
+
+type indexRule struct {
+	kind string
+	desc string
+	cond RuleCond
+}
+
+func NewRuleSet(name string, cfg map[string]interface{}) (*RuleSet, error) {
+	res := &RuleSet{name: name}
+
+	for _, field := range ruleShortFields {
+		values, ok := cfg[string(field)]
+		if !ok {
+			continue
+		}
+
+		only, except, err := parseShortRule(field, values)
+		if err != nil {
+			return nil, fmt.Errorf("%s rules: %w", name, err)
+		}
+
+		if len(only) > 0 {
+			res.rules = append(res.rules, indexRule{RuleOnly, string(field), newContainsMatcher(field, only)})
+		}
+
+		if len(except) > 0 {
+			res.rules = append(res.rules, indexRule{RuleExcept, string(field), newContainsMatcher(field, except)})
+		}
 	}
 
-	return
+	for _, kind := range []string{RuleAccept, RuleReject} {
+		for _, item := range toRuleList(cfg[kind]) {
+			cond, err := ParseRuleCond(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s rules: %s: %w", name, kind, err)
+			}
+
+			res.rules = append(res.rules, indexRule{kind, kind + " " + cond.String(), cond})
+		}
+	}
+
+	for key := range cfg {
+		if key != RuleAccept && key != RuleReject && !hasRuleField(ruleShortFields, IndexField(key)) {
+			return nil, fmt.Errorf("%s rules: invalid rule field %s", name, key)
+		}
+	}
+
+	return res, nil
 }
 
-func (r IndexRules) checkOnly(rule IndexField, got string) bool {
-	if len(r[string(rule)+"-only"]) == 0 {
-		return true
+// parseShortRule splits short form values to required and excepted ones, required values are used without
+// excepted ones if both are defined.
+func parseShortRule(field IndexField, values interface{}) (only, except []string, err error) {
+	list, err := cast.ToStringSliceE(values)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s should be a list of values", field)
 	}
 
-	if got == "" {
-		return false
+	for _, val := range list {
+		val = strings.TrimSpace(val)
+		if val == "" {
+			continue
+		}
+
+		excepted := val[0:1] == "-"
+
+		if val = normalizeRuleValue(field, strings.TrimLeft(val, "+-")); val == "" {
+			continue
+		}
+
+		if excepted {
+			except = append(except, val)
+		} else if !SliceHasString(only, val) {
+			only = append(only, val)
+		}
 	}
 
-	for rule := range r[string(rule)+"-only"] {
-		if strings.Contains(got, rule) {
-			return true
+	if len(only) > 0 && len(except) > 0 {
+		filtered := only[:0]
+
+		for _, val := range only {
+			if !SliceHasString(except, val) {
+				filtered = append(filtered, val)
+			}
 		}
+
+		only, except = filtered, nil
+	}
+
+	return
+}
+
+func (r indexRule) check(set string, values RuleValues) RuleResult {
+	res := RuleResult{
+		Set:     set,
+		Kind:    r.kind,
+		Rule:    r.desc,
+		Cond:    r.cond.String(),
+		Field:   ruleCondField(r.cond),
+		Value:   values.describe(r.cond.fields()),
+		Matched: r.cond.Match(values),
+	}
+
+	res.Passed = res.Matched == (r.kind == RuleOnly || r.kind == RuleAccept)
+
+	return res
+}
+
+// ruleCondField returns field of the condition or name of the combination for conditions with several fields.
+func ruleCondField(cond RuleCond) IndexField {
+	if fields := cond.fields(); len(fields) == 1 {
+		return fields[0]
 	}
 
-	return false
+	if comb, ok := cond.(*ruleComb); ok {
+		return IndexField(comb.op)
+	}
+
+	return IdxFUndefined
+}
+
+var ruleShortFields = []IndexField{
+	IdxFLang, IdxFGenre, IdxFISBN, IdxFAuthor, IdxFTranslator, IdxFSerie, IdxFDate, IdxFPublisher, IdxFTitle,
+}
+
+// RuleValues are lowercased values of book fields, which are checked by index rules.
+type RuleValues struct {
+	strs map[IndexField][]string
+	nums map[IndexField]float64
 }
 
-func (r IndexRules) checkExcept(rule IndexField, got string) bool {
-	if len(r[string(rule)+"-except"]) == 0 {
-		return true
+func NewRuleValues(book *Book) RuleValues {
+	res := RuleValues{
+		strs: map[IndexField][]string{},
+		nums: map[IndexField]float64{},
 	}
 
-	if got == "" {
-		return true
+	add := func(field IndexField, vals ...string) {
+		for _, val := range vals {
+			if val = strings.ToLower(strings.TrimSpace(val)); val != "" && !SliceHasString(res.strs[field], val) {
+				res.strs[field] = append(res.strs[field], val)
+			}
+		}
+	}
+
+	metas := []BookMeta{book.Info}
+	if book.OrigInfo != nil {
+		metas = append(metas, *book.OrigInfo)
+	}
+
+	for _, meta := range metas {
+		add(IdxFTitle, meta.Title)
+		add(IdxFDate, meta.Date)
+		add(IdxFAuthor, meta.Authors...)
+		add(IdxFTranslator, meta.Translators...)
+		add(IdxFSerie, meta.Sequences.Names()...)
 	}
 
-	for rule := range r[string(rule)+"-except"] {
-		if strings.Contains(got, rule) {
-			return false
+	for _, publ := range book.PublInfo {
+		add(IdxFTitle, publ.Title)
+		add(IdxFDate, publ.Year)
+		add(IdxFAuthor, publ.Authors...)
+		add(IdxFSerie, publ.Sequences.Names()...)
+		add(IdxFPublisher, publ.Publisher)
+	}
+
+	// books without language are treated as russian ones by the lang rules
+	if lang := book.Info.Lang; lang != "" {
+		add(IdxFLang, lang)
+	} else {
+		add(IdxFLang, "ru")
+	}
+
+	add(IdxFGenre, WithParentGenres(book.Genres())...)
+	add(IdxFISBN, book.ISBNTerms()...)
+	add(IdxFLib, book.Lib)
+
+	res.nums[IdxFYear] = float64(ParseYear(strings.Join(res.strs[IdxFDate], " ")))
+	res.nums[IdxFSize] = float64(book.Size)
+
+	return res
+}
+
+func (v RuleValues) Strings(field IndexField) []string {
+	return v.strs[field]
+}
+
+// Number returns numeric value of the field, zero is returned for unknown values.
+func (v RuleValues) Number(field IndexField) float64 {
+	return v.nums[field]
+}
+
+func (v RuleValues) describe(fields []IndexField) string {
+	res := make([]string, 0, len(fields))
+
+	for _, field := range fields {
+		var val string
+
+		if isNumericRuleField(field) {
+			val = cast.ToString(v.nums[field])
+		} else {
+			val = strings.Join(v.strs[field], ", ")
+		}
+
+		if len(fields) == 1 {
+			return val
 		}
+
+		res = append(res, fmt.Sprintf("%s=%s", field, val))
 	}
 
-	return true
+	sort.Strings(res)
+
+	return strings.Join(res, "; ")
 }
@@ -0,0 +1,148 @@
+package entities
+
+import (
+	"time"
+)
+
+const (
+	IndexRunFinished    = "finished"
+	IndexRunCanceled    = "canceled"
+	IndexRunInterrupted = "interrupted"
+	IndexRunFailed      = "failed"
+
+	IndexRunModeIndex = "index"
+	IndexRunModeRetry = "retry"
+)
+
+// IndexRunCounts counts books of the indexing run, skipped books are counted by fields of index rules.
+type IndexRunCounts struct {
+	Found      int            `json:"found"`
+	Indexed    int            `json:"indexed"`
+	Skipped    int            `json:"skipped"`
+	SkippedBy  map[string]int `json:"skipped_by,omitempty"`
+	Failed     int            `json:"failed"`
+	Duplicated int            `json:"duplicated"`
+	Bytes      int64          `json:"bytes"`
+}
+
+func (c *IndexRunCounts) skip(field string) {
+	if c.SkippedBy == nil {
+		c.SkippedBy = map[string]int{}
+	}
+
+	c.Skipped++
+	c.SkippedBy[field]++
+}
+
+type IndexRunLib struct {
+	IndexRunCounts
+	Archives map[string]*IndexRunCounts `json:"archives,omitempty"`
+}
+
+// IndexRun is a report of the indexing run with counts of books by libraries and archives.
+type IndexRun struct {
+	ID         string    `json:"id"`
+	Mode       string    `json:"mode"`
+	Lib        string    `json:"lib,omitempty"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+	Duration   float64   `json:"duration"`
+	Throughput float64   `json:"throughput"`
+	IndexRunCounts
+	Libs map[string]*IndexRunLib `json:"libs"`
+}
+
+func NewIndexRun(mode, lib string) *IndexRun {
+	now := time.Now()
+
+	return &IndexRun{
+		ID:      now.UTC().Format("20060102T150405.000000000"),
+		Mode:    mode,
+		Lib:     lib,
+		Started: now,
+		Libs:    map[string]*IndexRunLib{},
+	}
+}
+
+// Found counts the book, which is read for indexing.
+func (r *IndexRun) Found(book Book) {
+	size := int64(book.SizeCompressed)
+	if size == 0 {
+		size = int64(book.Size)
+	}
+
+	r.count(book, func(cnt *IndexRunCounts) {
+		cnt.Found++
+		cnt.Bytes += size
+	})
+}
+
+// Indexed counts the saved book, the book is counted as duplicated also if it is marked so.
+func (r *IndexRun) Indexed(book Book) {
+	r.count(book, func(cnt *IndexRunCounts) {
+		cnt.Indexed++
+
+		if book.DupOf != "" {
+			cnt.Duplicated++
+		}
+	})
+}
+
+// Skipped counts the book, which doesn't match index rules by the field.
+func (r *IndexRun) Skipped(book Book, field string) {
+	r.count(book, func(cnt *IndexRunCounts) { cnt.skip(field) })
+}
+
+// Duplicated counts the book, which isn't saved, because the newer revision of it is indexed already.
+func (r *IndexRun) Duplicated(book Book) {
+	r.count(book, func(cnt *IndexRunCounts) { cnt.Duplicated++ })
+}
+
+func (r *IndexRun) Failed(book Book) {
+	r.count(book, func(cnt *IndexRunCounts) { cnt.Failed++ })
+}
+
+// Finish sets status of the run by the error and calculates duration and throughput in bytes per second.
+func (r *IndexRun) Finish(status string, err error) {
+	r.Status, r.Finished = status, time.Now()
+	r.Duration = r.Finished.Sub(r.Started).Seconds()
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	if r.Duration > 0 {
+		r.Throughput = float64(r.Bytes) / r.Duration
+	}
+}
+
+func (r *IndexRun) count(book Book, fn func(*IndexRunCounts)) {
+	fn(&r.IndexRunCounts)
+
+	lib, ok := r.Libs[book.Lib]
+	if !ok {
+		lib = &IndexRunLib{}
+		r.Libs[book.Lib] = lib
+	}
+
+	fn(&lib.IndexRunCounts)
+
+	archivePath, _ := book.Archive()
+	if archivePath == "" {
+		return
+	}
+
+	if lib.Archives == nil {
+		lib.Archives = map[string]*IndexRunCounts{}
+	}
+
+	archive, ok := lib.Archives[archivePath]
+	if !ok {
+		archive = &IndexRunCounts{}
+		lib.Archives[archivePath] = archive
+	}
+
+	fn(archive)
+}
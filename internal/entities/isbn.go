@@ -0,0 +1,134 @@
+package entities
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	isbnSplitPattern = regexp.MustCompile(`[,;/|]+|\s{2,}`)
+	isbnCleanPattern = regexp.MustCompile(`[^0-9X]+`)
+
+	ErrISBNInvalid  = errors.New("invalid isbn")
+	ErrISBNChecksum = errors.New("invalid isbn checksum")
+)
+
+type ISBN struct {
+	ISBN13 string
+	ISBN10 string
+}
+
+func (i ISBN) String() string {
+	return i.ISBN13
+}
+
+// Terms returns all isbn forms which should lead to the book.
+func (i ISBN) Terms() []string {
+	if i.ISBN10 != "" {
+		return []string{i.ISBN13, i.ISBN10}
+	}
+
+	return []string{i.ISBN13}
+}
+
+func CleanISBN(val string) string {
+	val = strings.ToUpper(strings.TrimSpace(val))
+	val = strings.TrimPrefix(strings.TrimPrefix(val, "ISBN-13"), "ISBN-10")
+	val = strings.TrimPrefix(val, "ISBN")
+
+	return isbnCleanPattern.ReplaceAllString(val, "")
+}
+
+// ParseISBN validates isbn-10 or isbn-13 value and converts it to canonical isbn-13 with isbn-10 equivalent.
+func ParseISBN(val string) (res ISBN, err error) {
+	val = CleanISBN(val)
+
+	switch len(val) {
+	case 10:
+		if strings.IndexByte(val, 'X') >= 0 && strings.IndexByte(val, 'X') != 9 {
+			return res, ErrISBNInvalid
+		}
+
+		if isbn10Checksum(val[:9]) != val[9] {
+			return res, ErrISBNChecksum
+		}
+
+		res.ISBN10 = val
+		res.ISBN13 = "978" + val[:9]
+		res.ISBN13 += string(isbn13Checksum(res.ISBN13))
+	case 13:
+		if strings.IndexByte(val, 'X') >= 0 || !(strings.HasPrefix(val, "978") || strings.HasPrefix(val, "979")) {
+			return res, ErrISBNInvalid
+		}
+
+		if isbn13Checksum(val[:12]) != val[12] {
+			return res, ErrISBNChecksum
+		}
+
+		res.ISBN13 = val
+
+		if strings.HasPrefix(val, "978") {
+			res.ISBN10 = val[3:12] + string(isbn10Checksum(val[3:12]))
+		}
+	default:
+		return res, ErrISBNInvalid
+	}
+
+	return
+}
+
+// ParseISBNs extracts valid isbns from the raw field, invalid parts are returned cleaned.
+func ParseISBNs(val string) (res []ISBN, invalid []string) {
+	for _, part := range isbnSplitPattern.Split(val, -1) {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+
+		isbn, err := ParseISBN(part)
+		if err == nil {
+			res = append(res, isbn)
+			continue
+		}
+
+		if fields := strings.Fields(part); len(fields) > 1 && len(CleanISBN(part)) > 13 {
+			items, rest := ParseISBNs(strings.Join(fields, ","))
+			res, invalid = append(res, items...), append(invalid, rest...)
+
+			continue
+		}
+
+		if cleaned := CleanISBN(part); cleaned != "" {
+			invalid = append(invalid, cleaned)
+		}
+	}
+
+	return
+}
+
+func isbn10Checksum(digits string) byte {
+	var sum int
+	for k := 0; k < 9; k++ {
+		sum += int(digits[k]-'0') * (10 - k)
+	}
+
+	switch check := (11 - sum%11) % 11; check {
+	case 10:
+		return 'X'
+	default:
+		return byte('0' + check)
+	}
+}
+
+func isbn13Checksum(digits string) byte {
+	var sum int
+	for k := 0; k < 12; k++ {
+		if k%2 == 0 {
+			sum += int(digits[k] - '0')
+		} else {
+			sum += int(digits[k]-'0') * 3
+		}
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
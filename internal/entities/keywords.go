@@ -0,0 +1,88 @@
+package entities
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	keywordMaxLen     = 64
+	TagsCloudLevelMax = 5
+)
+
+var keywordsSepPattern = regexp.MustCompile(`[,;\n\r\t]+`)
+
+// NormalizeKeyword returns keyword as it is stored at the index.
+func NormalizeKeyword(val string) string {
+	val = strings.Trim(strings.ToLower(strings.Join(strings.Fields(val), " ")), ".!?\"'«»")
+	if len([]rune(val)) > keywordMaxLen {
+		return ""
+	}
+
+	return strings.ReplaceAll(val, "ё", "е")
+}
+
+// ParseKeywords splits fb2 keywords string to separate tags.
+func ParseKeywords(val string) (res []string) {
+	for _, item := range keywordsSepPattern.Split(val, -1) {
+		if item = NormalizeKeyword(item); item != "" && !SliceHasString(res, item) {
+			res = append(res, item)
+		}
+	}
+
+	return
+}
+
+func (m BookMeta) Tags() []string {
+	return ParseKeywords(m.Keywords)
+}
+
+func (b *Book) Keywords() []string {
+	res := ParseKeywords(b.Info.Keywords)
+
+	if b.OrigInfo != nil {
+		for _, item := range ParseKeywords(b.OrigInfo.Keywords) {
+			if !SliceHasString(res, item) {
+				res = append(res, item)
+			}
+		}
+	}
+
+	return res
+}
+
+type TagsCloudItem struct {
+	Val   string
+	Freq  int
+	Level int
+}
+
+// NewTagsCloud returns tags sorted by name with levels from 1 to TagsCloudLevelMax by their frequencies.
+func NewTagsCloud(items FreqsItems, limit int) []TagsCloudItem {
+	sort.Sort(sort.Reverse(items))
+
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	maxFreq, minFreq := items[0].Freq, items[len(items)-1].Freq
+	res := make([]TagsCloudItem, 0, len(items))
+
+	for _, item := range items {
+		level := 1
+		if maxFreq > minFreq {
+			level += (item.Freq - minFreq) * (TagsCloudLevelMax - 1) / (maxFreq - minFreq)
+		}
+
+		res = append(res, TagsCloudItem{Val: item.Val, Freq: item.Freq, Level: level})
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Val < res[j].Val })
+
+	return res
+}
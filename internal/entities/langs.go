@@ -0,0 +1,402 @@
+package entities
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	LangScriptLatin    = "latn"
+	LangScriptCyrillic = "cyrl"
+	LangScriptGreek    = "grek"
+	LangScriptArabic   = "arab"
+	LangScriptHebrew   = "hebr"
+	LangScriptCJK      = "hani"
+	LangScriptOther    = "other"
+)
+
+var langRegionPattern = regexp.MustCompile(`[-_].*$`)
+
+type Lang struct {
+	Code    string
+	Script  string
+	Aliases []string
+	Titles  map[string]string
+}
+
+func (l *Lang) Title(lang string) string {
+	if title, ok := l.Titles[lang]; ok {
+		return title
+	}
+
+	if title, ok := l.Titles[GenreLangRu]; ok {
+		return title
+	}
+
+	return l.Code
+}
+
+// https://en.wikipedia.org/wiki/List_of_ISO_639-1_codes
+var isoLangsTable = []Lang{
+	{"ru", LangScriptCyrillic, []string{"rus", "russian", "русский", "ru_ru", "ру", "рус"}, map[string]string{"ru": "Русский", "en": "Russian"}},
+	{"uk", LangScriptCyrillic, []string{"ukr", "ukrainian", "українська", "украинский", "ua"}, map[string]string{"ru": "Украинский", "en": "Ukrainian"}},
+	{"be", LangScriptCyrillic, []string{"bel", "belarusian", "беларуская", "белорусский", "by"}, map[string]string{"ru": "Белорусский", "en": "Belarusian"}},
+	{"bg", LangScriptCyrillic, []string{"bul", "bulgarian", "български", "болгарский"}, map[string]string{"ru": "Болгарский", "en": "Bulgarian"}},
+	{"sr", LangScriptCyrillic, []string{"srp", "serbian", "српски", "сербский"}, map[string]string{"ru": "Сербский", "en": "Serbian"}},
+	{"mk", LangScriptCyrillic, []string{"mkd", "mac", "macedonian", "македонский"}, map[string]string{"ru": "Македонский", "en": "Macedonian"}},
+	{"kk", LangScriptCyrillic, []string{"kaz", "kazakh", "қазақ", "казахский"}, map[string]string{"ru": "Казахский", "en": "Kazakh"}},
+	{"tt", LangScriptCyrillic, []string{"tat", "tatar", "татарча", "татарский"}, map[string]string{"ru": "Татарский", "en": "Tatar"}},
+	{"ba", LangScriptCyrillic, []string{"bak", "bashkir", "башкирский"}, map[string]string{"ru": "Башкирский", "en": "Bashkir"}},
+	{"cv", LangScriptCyrillic, []string{"chv", "chuvash", "чувашский"}, map[string]string{"ru": "Чувашский", "en": "Chuvash"}},
+	{"mn", LangScriptCyrillic, []string{"mon", "mongolian", "монгольский"}, map[string]string{"ru": "Монгольский", "en": "Mongolian"}},
+	{"ky", LangScriptCyrillic, []string{"kir", "kyrgyz", "kirghiz", "киргизский"}, map[string]string{"ru": "Киргизский", "en": "Kyrgyz"}},
+	{"tg", LangScriptCyrillic, []string{"tgk", "tajik", "таджикский"}, map[string]string{"ru": "Таджикский", "en": "Tajik"}},
+	{"en", LangScriptLatin, []string{"eng", "english", "английский", "en_us", "en_gb"}, map[string]string{"ru": "Английский", "en": "English"}},
+	{"de", LangScriptLatin, []string{"deu", "ger", "german", "deutsch", "немецкий"}, map[string]string{"ru": "Немецкий", "en": "German"}},
+	{"fr", LangScriptLatin, []string{"fra", "fre", "french", "français", "francais", "французский"}, map[string]string{"ru": "Французский", "en": "French"}},
+	{"es", LangScriptLatin, []string{"spa", "spanish", "español", "espanol", "испанский"}, map[string]string{"ru": "Испанский", "en": "Spanish"}},
+	{"it", LangScriptLatin, []string{"ita", "italian", "italiano", "итальянский"}, map[string]string{"ru": "Итальянский", "en": "Italian"}},
+	{"pt", LangScriptLatin, []string{"por", "portuguese", "português", "португальский"}, map[string]string{"ru": "Португальский", "en": "Portuguese"}},
+	{"pl", LangScriptLatin, []string{"pol", "polish", "polski", "польский"}, map[string]string{"ru": "Польский", "en": "Polish"}},
+	{"cs", LangScriptLatin, []string{"ces", "cze", "czech", "čeština", "чешский", "cz"}, map[string]string{"ru": "Чешский", "en": "Czech"}},
+	{"sk", LangScriptLatin, []string{"slk", "slo", "slovak", "словацкий"}, map[string]string{"ru": "Словацкий", "en": "Slovak"}},
+	{"sl", LangScriptLatin, []string{"slv", "slovenian", "словенский"}, map[string]string{"ru": "Словенский", "en": "Slovenian"}},
+	{"hr", LangScriptLatin, []string{"hrv", "croatian", "хорватский"}, map[string]string{"ru": "Хорватский", "en": "Croatian"}},
+	{"bs", LangScriptLatin, []string{"bos", "bosnian", "боснийский"}, map[string]string{"ru": "Боснийский", "en": "Bosnian"}},
+	{"nl", LangScriptLatin, []string{"nld", "dut", "dutch", "nederlands", "нидерландский", "голландский"}, map[string]string{"ru": "Нидерландский", "en": "Dutch"}},
+	{"sv", LangScriptLatin, []string{"swe", "swedish", "svenska", "шведский"}, map[string]string{"ru": "Шведский", "en": "Swedish"}},
+	{"no", LangScriptLatin, []string{"nor", "nob", "nb", "nn", "norwegian", "norsk", "норвежский"}, map[string]string{"ru": "Норвежский", "en": "Norwegian"}},
+	{"da", LangScriptLatin, []string{"dan", "danish", "dansk", "датский"}, map[string]string{"ru": "Датский", "en": "Danish"}},
+	{"fi", LangScriptLatin, []string{"fin", "finnish", "suomi", "финский"}, map[string]string{"ru": "Финский", "en": "Finnish"}},
+	{"et", LangScriptLatin, []string{"est", "estonian", "eesti", "эстонский"}, map[string]string{"ru": "Эстонский", "en": "Estonian"}},
+	{"lv", LangScriptLatin, []string{"lav", "latvian", "latviešu", "латышский"}, map[string]string{"ru": "Латышский", "en": "Latvian"}},
+	{"lt", LangScriptLatin, []string{"lit", "lithuanian", "lietuvių", "литовский"}, map[string]string{"ru": "Литовский", "en": "Lithuanian"}},
+	{"hu", LangScriptLatin, []string{"hun", "hungarian", "magyar", "венгерский"}, map[string]string{"ru": "Венгерский", "en": "Hungarian"}},
+	{"ro", LangScriptLatin, []string{"ron", "rum", "romanian", "română", "румынский", "mo", "mol"}, map[string]string{"ru": "Румынский", "en": "Romanian"}},
+	{"tr", LangScriptLatin, []string{"tur", "turkish", "türkçe", "турецкий"}, map[string]string{"ru": "Турецкий", "en": "Turkish"}},
+	{"az", LangScriptLatin, []string{"aze", "azerbaijani", "азербайджанский"}, map[string]string{"ru": "Азербайджанский", "en": "Azerbaijani"}},
+	{"uz", LangScriptLatin, []string{"uzb", "uzbek", "узбекский"}, map[string]string{"ru": "Узбекский", "en": "Uzbek"}},
+	{"la", LangScriptLatin, []string{"lat", "latin", "латинский", "латынь"}, map[string]string{"ru": "Латинский", "en": "Latin"}},
+	{"eo", LangScriptLatin, []string{"epo", "esperanto", "эсперанто"}, map[string]string{"ru": "Эсперанто", "en": "Esperanto"}},
+	{"ca", LangScriptLatin, []string{"cat", "catalan", "català", "каталанский"}, map[string]string{"ru": "Каталанский", "en": "Catalan"}},
+	{"ga", LangScriptLatin, []string{"gle", "irish", "ирландский"}, map[string]string{"ru": "Ирландский", "en": "Irish"}},
+	{"is", LangScriptLatin, []string{"isl", "ice", "icelandic", "исландский"}, map[string]string{"ru": "Исландский", "en": "Icelandic"}},
+	{"id", LangScriptLatin, []string{"ind", "indonesian", "индонезийский"}, map[string]string{"ru": "Индонезийский", "en": "Indonesian"}},
+	{"vi", LangScriptLatin, []string{"vie", "vietnamese", "вьетнамский"}, map[string]string{"ru": "Вьетнамский", "en": "Vietnamese"}},
+	{"el", LangScriptGreek, []string{"ell", "gre", "greek", "ελληνικά", "греческий", "gr"}, map[string]string{"ru": "Греческий", "en": "Greek"}},
+	{"he", LangScriptHebrew, []string{"heb", "iw", "hebrew", "иврит"}, map[string]string{"ru": "Иврит", "en": "Hebrew"}},
+	{"yi", LangScriptHebrew, []string{"yid", "ji", "yiddish", "идиш"}, map[string]string{"ru": "Идиш", "en": "Yiddish"}},
+	{"ar", LangScriptArabic, []string{"ara", "arabic", "арабский"}, map[string]string{"ru": "Арабский", "en": "Arabic"}},
+	{"fa", LangScriptArabic, []string{"fas", "per", "persian", "farsi", "персидский"}, map[string]string{"ru": "Персидский", "en": "Persian"}},
+	{"zh", LangScriptCJK, []string{"zho", "chi", "chinese", "китайский", "cn"}, map[string]string{"ru": "Китайский", "en": "Chinese"}},
+	{"ja", LangScriptCJK, []string{"jpn", "japanese", "японский", "jp"}, map[string]string{"ru": "Японский", "en": "Japanese"}},
+	{"ko", LangScriptOther, []string{"kor", "korean", "корейский", "kr"}, map[string]string{"ru": "Корейский", "en": "Korean"}},
+	{"ka", LangScriptOther, []string{"kat", "geo", "georgian", "грузинский"}, map[string]string{"ru": "Грузинский", "en": "Georgian"}},
+	{"hy", LangScriptOther, []string{"hye", "arm", "armenian", "армянский"}, map[string]string{"ru": "Армянский", "en": "Armenian"}},
+	{"hi", LangScriptOther, []string{"hin", "hindi", "хинди"}, map[string]string{"ru": "Хинди", "en": "Hindi"}},
+}
+
+var isoLangs = func() map[string]*Lang {
+	res := make(map[string]*Lang, len(isoLangsTable)*6)
+
+	for k := range isoLangsTable {
+		res[isoLangsTable[k].Code] = &isoLangsTable[k]
+
+		for _, alias := range isoLangsTable[k].Aliases {
+			res[alias] = &isoLangsTable[k]
+		}
+
+		for _, title := range isoLangsTable[k].Titles {
+			res[strings.ToLower(title)] = &isoLangsTable[k]
+		}
+	}
+
+	return res
+}()
+
+// NormalizeLang converts language tag to ISO 639-1 code, known flag is false for unrecognized values.
+func NormalizeLang(val string) (res string, known bool) {
+	res = strings.ToLower(strings.TrimSpace(val))
+	if res == "" {
+		return
+	}
+
+	if lang, ok := isoLangs[res]; ok {
+		return lang.Code, true
+	}
+
+	if lang, ok := isoLangs[langRegionPattern.ReplaceAllString(res, "")]; ok {
+		return lang.Code, true
+	}
+
+	return
+}
+
+func GetLang(val string) *Lang {
+	code, _ := NormalizeLang(val)
+
+	return isoLangs[code]
+}
+
+func GetLangTitle(val, lang string) string {
+	if item := GetLang(val); item != nil {
+		return item.Title(lang)
+	}
+
+	return val
+}
+
+// langSamples are typical texts of languages, their character n-grams are used as languages profiles.
+var langSamples = map[string]string{
+	"ru": "Это была книга о жизни и любви. Он сказал, что она не может прийти, потому что было уже поздно. " +
+		"Когда они вышли из дома, все люди на улице смотрели только на них. Который час, спросил я. " +
+		"Мы всегда читаем вечером, и это нам очень нравится. Ещё одна история о том, как объяснить необъяснимое." +
+		"и в не на что он с как это его но она я к по из так было все за от они же бы только когда который книга жизни " +
+		"Новый роман известного писателя рассказывает о приключениях молодого человека в далёких странах. Герои книги путешествуют по морям, встречают друзей и врагов, ищут сокровища и теряют близких. Автор описывает события войны, судьбы людей и жизнь небольшого города, где каждый знает друг друга. Читатель узнает, чем закончилась эта удивительная история, полная тайн, надежды и приключений. Сборник стихов и рассказов для детей и взрослых.",
+	"uk": "Це була книга про життя і кохання. Він сказав, що вона не може прийти, тому що було вже пізно. " +
+		"Коли вони вийшли з дому, всі люди на вулиці дивилися тільки на них. Котра година, запитав я. " +
+		"Ми завжди читаємо ввечері, і це нам дуже подобається. Її історія про те, як пояснити їхнє ґанок." +
+		"і в не на що він з як це його але вона я до та від було вони же би тільки коли який книга життя " +
+		"Новий роман відомого письменника розповідає про пригоди молодої людини в далеких країнах. Герої книжки подорожують морями, зустрічають друзів і ворогів, шукають скарби та втрачають близьких. Автор описує події війни, долі людей і життя невеликого міста, де кожен знає одне одного. Читач дізнається, чим закінчилася ця дивовижна історія, сповнена таємниць, надії та пригод. Збірка віршів і оповідань для дітей та дорослих.",
+	"be": "Гэта была кніга пра жыццё і каханне. Ён сказаў, што яна не можа прыйсці, таму што было ўжо позна. " +
+		"Калі яны выйшлі з дому, усе людзі на вуліцы глядзелі толькі на іх. Які час, спытаў я. " +
+		"Мы заўсёды чытаем увечары, і гэта нам вельмі падабаецца. Адна гісторыя пра тое, як растлумачыць усё." +
+		"і у не на што ён з як гэта яго але яна я да ад было яны толькі калі які кніга " +
+		"Новы раман вядомага пісьменніка апавядае пра прыгоды маладога чалавека ў далёкіх краінах. Героі кнігі падарожнічаюць па морах, сустракаюць сяброў і ворагаў, шукаюць скарбы і губляюць блізкіх. Аўтар апісвае падзеі вайны, лёсы людзей і жыццё невялікага горада, дзе кожны ведае адзін аднаго. Чытач даведаецца, чым скончылася гэтая дзіўная гісторыя, поўная таямніц, надзеі і прыгод. Зборнік вершаў і апавяданняў для дзяцей і дарослых.",
+	"bg": "Това беше книга за живота и любовта. Той каза, че тя не може да дойде, защото беше вече късно. " +
+		"Когато излязоха от къщи, всички хора на улицата гледаха само към тях. Колко е часът, попитах аз. " +
+		"Ние винаги четем вечер и това много ни харесва. Още една история за това, който ще обясни всичко." +
+		"и в не на че той с като това но тя аз за от беше са се да ще книга който " +
+		"Новият роман на известния писател разказва за приключенията на един млад човек в далечни страни. Героите на книгата пътуват по моретата, срещат приятели и врагове, търсят съкровища и губят близки хора. Авторът описва събитията от войната, съдбите на хората и живота в малкия град, където всеки познава всеки. Читателят ще разбере как завършва тази удивителна история, пълна с тайни и надежда. Сборник със стихове и разкази за деца и възрастни.",
+	"sr": "Ово је била књига о животу и љубави. Он је рекао да она не може да дође, јер је већ било касно. " +
+		"Када су изашли из куће, сви људи на улици гледали су само у њих. Колико је сати, питао сам. " +
+		"Ми увек читамо увече и то нам се веома допада. Још једна прича о томе како објаснити ђачку љубав." +
+		"и у не на да је се су од за као али што који " +
+		"Нови роман познатог писца прича о авантурама младог човека у далеким земљама. Јунаци књиге путују морима, сусрећу пријатеље и непријатеље, траже благо и губе блиске људе. Аутор описује догађаје из рата, судбине људи и живот у малом граду, где свако познаје свакога. Читалац ће сазнати како се завршила ова необична прича, пуна тајни и наде. Збирка песама и прича за децу и одрасле.",
+	"en": "This was a book about life and love. He said that she could not come, because it was already late. " +
+		"When they went out of the house, all the people in the street were looking only at them. What time is it, " +
+		"I asked. We always read in the evening, and we like it very much. Another story which explains everything." +
+		"the and of to in is that it was he for with his as on be at by this had not but from they she her book which",
+	"de": "Das war ein Buch über das Leben und die Liebe. Er sagte, dass sie nicht kommen könne, weil es schon spät " +
+		"war. Als sie aus dem Haus gingen, schauten alle Leute auf der Straße nur auf sie. Wie spät ist es, fragte " +
+		"ich. Wir lesen immer am Abend, und das gefällt uns sehr. Noch eine Geschichte, die sich für alles erklärt." +
+		"der die und in den von zu das mit sich des auf für ist im dem nicht ein eine als auch es an er hat aus sie",
+	"fr": "C'était un livre sur la vie et l'amour. Il a dit qu'elle ne pouvait pas venir, parce qu'il était déjà " +
+		"tard. Quand ils sont sortis de la maison, tous les gens dans la rue ne regardaient qu'eux. Quelle heure " +
+		"est-il, ai-je demandé. Nous lisons toujours le soir, et cela nous plaît beaucoup. Une autre histoire." +
+		"le la les de des et en un une du est que qui dans pour pas au sur il elle avec ce son sa par",
+	"es": "Era un libro sobre la vida y el amor. Él dijo que ella no podía venir, porque ya era tarde. Cuando " +
+		"salieron de la casa, todas las personas en la calle los miraban solo a ellos. ¿Qué hora es?, pregunté. " +
+		"Siempre leemos por la noche, y eso nos gusta mucho. Otra historia que lo explica todo, pero más larga." +
+		"el la de que y en los las del se un una por con no es para su al lo como más pero",
+	"it": "Era un libro sulla vita e sull'amore. Lui disse che lei non poteva venire, perché era già tardi. " +
+		"Quando uscirono di casa, tutte le persone nella strada guardavano solo loro. Che ora è, chiesi. Noi " +
+		"leggiamo sempre la sera, e questo ci piace molto. Un'altra storia della famiglia che spiega tutto." +
+		"il di che e la le un una per non del della sono con gli nel è si da ma come anche",
+	"pt": "Era um livro sobre a vida e o amor. Ele disse que ela não podia vir, porque já era tarde. Quando " +
+		"saíram de casa, todas as pessoas na rua olhavam só para eles. Que horas são, perguntei. Nós sempre " +
+		"lemos à noite, e isso nos agrada muito. Outra história que explica tudo, mas com ações e canções." +
+		"o a de que e do da em um uma para com não os as dos das se por mais ao é",
+	"pl": "To była książka o życiu i miłości. Powiedział, że ona nie może przyjść, ponieważ było już późno. " +
+		"Kiedy wyszli z domu, wszyscy ludzie na ulicy patrzyli tylko na nich. Która jest godzina, zapytałem. " +
+		"Zawsze czytamy wieczorem i to się nam bardzo podoba. Jeszcze jedna historia, która wszystko wyjaśnia." +
+		"i w nie na się z że do to jest jak o ale po co tak jego od przez był była",
+	"cs": "To byla kniha o životě a lásce. Řekl, že ona nemůže přijít, protože už bylo pozdě. Když vyšli z " +
+		"domu, všichni lidé na ulici se dívali jen na ně. Kolik je hodin, zeptal jsem se. Vždycky čteme " +
+		"večer a to se nám velmi líbí. Ještě jeden příběh, který všechno vysvětluje, ať je jakýkoli." +
+		"a v se na je že to s z do o jak ale by jsem byl jeho pro který",
+}
+
+// langProfile has log probabilities of character n-grams of the language.
+type langProfile struct {
+	probs   map[string]float64
+	unknown float64
+}
+
+var langScripts = []string{
+	LangScriptCyrillic, LangScriptLatin, LangScriptGreek, LangScriptArabic, LangScriptHebrew, LangScriptCJK,
+	LangScriptOther,
+}
+
+// langCodes are sorted codes of languages profiles.
+var langCodes = func() []string {
+	res := make([]string, 0, len(langSamples))
+	for code := range langSamples {
+		res = append(res, code)
+	}
+
+	sort.Strings(res)
+
+	return res
+}()
+
+// langProfiles are built from n-grams of languages samples.
+var langProfiles = func() map[string]langProfile {
+	res := make(map[string]langProfile, len(langSamples))
+
+	for code, sample := range langSamples {
+		ngrams := langNGrams(sample)
+		profile := langProfile{probs: make(map[string]float64, len(ngrams))}
+
+		var total float64
+		for _, cnt := range ngrams {
+			total += cnt
+		}
+
+		// additive smoothing, so n-grams missing at the sample don't reject the language
+		total += langSmoothing * float64(len(ngrams))
+		for ngram, cnt := range ngrams {
+			profile.probs[ngram] = math.Log((cnt + langSmoothing) / total)
+		}
+
+		profile.unknown = math.Log(langSmoothing / total)
+		res[code] = profile
+	}
+
+	return res
+}()
+
+const (
+	langMaxNGram  = 3
+	langSmoothing = 0.5
+	// langConfidenceScale sharpens differences of average n-grams likelihoods for the confidence
+	langConfidenceScale = 10
+)
+
+// langNGramWeights are weights of average likelihoods of n-grams by their size: letters frequencies are the most
+// reliable for short samples.
+var langNGramWeights = [langMaxNGram + 1]float64{1: 3, 2: 1, 3: 1}
+
+// langNGrams returns counts of character n-grams (from 1 to langMaxNGram runes) of the text words, which are padded
+// with spaces.
+func langNGrams(text string) map[string]float64 {
+	res := map[string]float64{}
+
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		runes := []rune(" " + word + " ")
+
+		for size := 1; size <= langMaxNGram; size++ {
+			for k := 0; k+size <= len(runes); k++ {
+				if ngram := string(runes[k : k+size]); ngram != " " {
+					res[ngram]++
+				}
+			}
+		}
+	}
+
+	return res
+}
+
+// DetectLang guesses text language by script and character n-grams, returns empty code when not sure.
+func DetectLang(text string) (code string, confidence float64) {
+	scripts := map[string]int{}
+	var total int
+
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+
+		total++
+
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			scripts[LangScriptCyrillic]++
+		case unicode.Is(unicode.Latin, r):
+			scripts[LangScriptLatin]++
+		case unicode.Is(unicode.Greek, r):
+			scripts[LangScriptGreek]++
+		case unicode.Is(unicode.Arabic, r):
+			scripts[LangScriptArabic]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts[LangScriptHebrew]++
+		case unicode.Is(unicode.Han, r):
+			scripts[LangScriptCJK]++
+		default:
+			scripts[LangScriptOther]++
+		}
+	}
+
+	if total < 20 {
+		return
+	}
+
+	// scripts and languages are ranged in the same order, so ties are broken by it and scores are summed equally
+	var script string
+	for _, name := range langScripts {
+		if script == "" || scripts[name] > scripts[script] {
+			script = name
+		}
+	}
+
+	switch script {
+	case LangScriptGreek:
+		return "el", float64(scripts[script]) / float64(total)
+	case LangScriptCJK:
+		return "zh", float64(scripts[script]) / float64(total)
+	case LangScriptCyrillic, LangScriptLatin:
+	default:
+		return
+	}
+
+	ngrams := langNGrams(text)
+	ngramsKeys := make([]string, 0, len(ngrams))
+	for ngram := range ngrams {
+		ngramsKeys = append(ngramsKeys, ngram)
+	}
+	sort.Strings(ngramsKeys)
+
+	scores := make(map[string]float64, len(langProfiles))
+
+	// every n-grams size affects the score equally
+	cnt := map[int]float64{}
+	for _, ngram := range ngramsKeys {
+		cnt[utf8.RuneCountInString(ngram)] += ngrams[ngram]
+	}
+
+	for _, lang := range langCodes {
+		if isoLangs[lang].Script != script {
+			continue
+		}
+
+		profile := langProfiles[lang]
+
+		// average log likelihood of the text n-grams
+		for _, ngram := range ngramsKeys {
+			freq := ngrams[ngram]
+			prob, ok := profile.probs[ngram]
+			if !ok {
+				prob = profile.unknown
+			}
+
+			size := utf8.RuneCountInString(ngram)
+			scores[lang] += langNGramWeights[size] * freq * prob / cnt[size]
+		}
+
+		if code == "" || scores[lang] > scores[code] {
+			code = lang
+		}
+	}
+
+	if code == "" {
+		return
+	}
+
+	// probability of the best language among languages of the script
+	var sum float64
+	for _, lang := range langCodes {
+		if score, ok := scores[lang]; ok {
+			sum += math.Exp((score - scores[code]) * langConfidenceScale)
+		}
+	}
+
+	confidence = 1 / sum
+
+	return
+}
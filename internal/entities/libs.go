@@ -67,8 +67,11 @@ type Library struct {
 	Dir      string        `mapstructure:"dir"`
 	Encoder  LibEncodeType `mapstructure:"encoder"`
 	Types    []string      `mapstructure:"types"`
+	// Rules are index rules of the library, they are checked after global ones.
+	Rules map[string]interface{} `mapstructure:"rules"`
 }
 
+// NewLibraries reads libraries by the config key, libraries without dir or with negative order are rejected.
 func NewLibraries(cfgKey string, cfg *viper.Viper) (Libraries, error) {
 	libs := Libraries{}
 
@@ -77,6 +80,13 @@ func NewLibraries(cfgKey string, cfg *viper.Viper) (Libraries, error) {
 	}
 
 	for name, lib := range libs {
+		switch {
+		case lib.Dir == "":
+			return nil, errors.Errorf("dir of %s library is not defined", name)
+		case lib.Order < 0:
+			return nil, errors.Errorf("order of %s library is negative", name)
+		}
+
 		lib.Name = name
 		libs[name] = lib
 	}
@@ -86,12 +96,18 @@ func NewLibraries(cfgKey string, cfg *viper.Viper) (Libraries, error) {
 
 func (l *Library) GetItems() (res []string, err error) {
 	os.MkdirAll(l.Dir, 0755)
-	err = filepath.Walk(l.Dir, func(pathStr string, info os.FileInfo, err error) error {
+
+	return l.GetDirItems(l.Dir)
+}
+
+// GetDirItems returns items of the library from the directory.
+func (l *Library) GetDirItems(dir string) (res []string, err error) {
+	err = filepath.Walk(dir, func(pathStr string, info os.FileInfo, err error) error {
 		if err != nil {
-			return errors.Wrapf(err, "walk %s/%s error", l.Dir, pathStr)
+			return errors.Wrapf(err, "walk %s/%s error", dir, pathStr)
 		}
 
-		if info.IsDir() || !SliceHasString(l.Types, strings.TrimPrefix(path.Ext(info.Name()), ".")) {
+		if info.IsDir() || !l.IsItem(info.Name()) {
 			return nil
 		}
 
@@ -103,6 +119,11 @@ func (l *Library) GetItems() (res []string, err error) {
 	return
 }
 
+// IsItem checks if the file is an item of the library by its type.
+func (l *Library) IsItem(name string) bool {
+	return SliceHasString(l.Types, strings.TrimPrefix(path.Ext(name), "."))
+}
+
 func (l *Library) GetSize() int64 {
 	items, err := l.GetItems()
 	if err != nil {
@@ -0,0 +1,167 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/egnd/go-xmlparse/fb2"
+)
+
+var serieNumPattern = regexp.MustCompile(`^(.*?)\s*\((\d+)\)$`)
+
+type BookSerie struct {
+	Name string `json:"name,omitempty"`
+	Num  int    `json:"num,omitempty"`
+}
+
+func NewBookSerie(data fb2.Sequence) (res BookSerie) {
+	for _, name := range strings.Split(data.Name, ",") {
+		if res.Name = strings.TrimSpace(name); res.Name != "" {
+			break
+		}
+	}
+
+	if res.Name != "" {
+		res.Num, _ = strconv.Atoi(strings.TrimSpace(data.Number))
+	}
+
+	if res.Num < 0 {
+		res.Num = 0
+	}
+
+	return
+}
+
+// ParseBookSerie converts legacy "Name (3)" serie string to structured value.
+func ParseBookSerie(val string) (res BookSerie) {
+	res.Name = strings.TrimSpace(val)
+
+	if match := serieNumPattern.FindStringSubmatch(res.Name); match != nil {
+		res.Name = strings.TrimSpace(match[1])
+		res.Num, _ = strconv.Atoi(match[2])
+	}
+
+	return
+}
+
+func (s BookSerie) String() string {
+	if s.Num > 0 {
+		return fmt.Sprintf("%s (%d)", s.Name, s.Num)
+	}
+
+	return s.Name
+}
+
+func (s *BookSerie) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+
+		*s = ParseBookSerie(str)
+
+		return nil
+	}
+
+	type plain BookSerie
+
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+type BookSeries []BookSerie
+
+func (s BookSeries) Names() []string {
+	res := make([]string, 0, len(s))
+
+	for _, item := range s {
+		if item.Name != "" {
+			res = append(res, item.Name)
+		}
+	}
+
+	return res
+}
+
+func (s BookSeries) Get(name string) *BookSerie {
+	name = strings.TrimSpace(name)
+
+	for k := range s {
+		if strings.EqualFold(s[k].Name, name) {
+			return &s[k]
+		}
+	}
+
+	return nil
+}
+
+type SerieVolume struct {
+	Num  int
+	Book *Book
+}
+
+func (v SerieVolume) Missing() bool {
+	return v.Book == nil
+}
+
+// maxSerieGap limits missing volumes between numbered ones, so bogus numbers (e.g. years) don't fill the serie
+// with thousands of missing volumes.
+const maxSerieGap = 10
+
+// NewSerieVolumes orders serie books by their numbers and fills gaps with missing volumes.
+func NewSerieVolumes(serie string, books []Book) []SerieVolume {
+	numbered := make(map[int][]*Book, len(books))
+	nums := make([]int, 0, len(books))
+	var unnumbered []*Book
+
+	for k := range books {
+		item := books[k].GetSerie(serie)
+		if item == nil {
+			continue
+		}
+
+		if item.Num <= 0 {
+			unnumbered = append(unnumbered, &books[k])
+			continue
+		}
+
+		if len(numbered[item.Num]) == 0 {
+			nums = append(nums, item.Num)
+		}
+
+		numbered[item.Num] = append(numbered[item.Num], &books[k])
+	}
+
+	sort.Ints(nums)
+
+	res := make([]SerieVolume, 0, len(books))
+
+	var prev int
+	for _, num := range nums {
+		if num-prev-1 <= maxSerieGap {
+			for missing := prev + 1; missing < num; missing++ {
+				res = append(res, SerieVolume{Num: missing})
+			}
+		}
+
+		sort.SliceStable(numbered[num], func(i, j int) bool {
+			return numbered[num][i].Info.Title < numbered[num][j].Info.Title
+		})
+
+		for _, book := range numbered[num] {
+			res = append(res, SerieVolume{Num: num, Book: book})
+		}
+
+		prev = num
+	}
+
+	for _, book := range unnumbered {
+		res = append(res, SerieVolume{Book: book})
+	}
+
+	return res
+}
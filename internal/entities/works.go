@@ -0,0 +1,76 @@
+package entities
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// WorkID identifies the work by original title and authors, so translations and reprints share it. Authors keys are
+// resolved to canonical authors ids, so misspelled or merged authors don't split the work.
+func (b *Book) WorkID(resolveAuthor func(string) string) string {
+	meta := &b.Info
+	if b.OrigInfo != nil && b.OrigInfo.Title != "" {
+		meta = b.OrigInfo
+	}
+
+	title := NormalizeDupTitle(meta.Title)
+	if title == "" {
+		return ""
+	}
+
+	refs := meta.refs()
+	if len(refs) == 0 {
+		refs = b.Info.refs()
+	}
+
+	keys := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		if key := resolveAuthor(ref.Key); key != "" && !SliceHasString(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	hash := md5.Sum([]byte(title + "|" + strings.Join(keys, ",")))
+
+	return hex.EncodeToString(hash[:8])
+}
+
+type Work struct {
+	ID      string
+	Title   string
+	Authors []string
+	Langs   []string
+	Books   []Book
+}
+
+func NewWork(workID string, books []Book) Work {
+	res := Work{ID: workID, Books: books}
+
+	sort.SliceStable(res.Books, func(i, j int) bool {
+		if res.Books[i].Info.Lang != res.Books[j].Info.Lang {
+			return res.Books[i].Info.Lang < res.Books[j].Info.Lang
+		}
+
+		return ParseYear(res.Books[i].Info.Date) < ParseYear(res.Books[j].Info.Date)
+	})
+
+	for _, book := range res.Books {
+		if res.Title == "" && book.OrigInfo != nil && book.OrigInfo.Title != "" {
+			res.Title, res.Authors = book.OrigInfo.Title, book.OrigInfo.Authors
+		}
+
+		if book.Info.Lang != "" && !SliceHasString(res.Langs, book.Info.Lang) {
+			res.Langs = append(res.Langs, book.Info.Lang)
+		}
+	}
+
+	if res.Title == "" && len(res.Books) > 0 {
+		res.Title, res.Authors = res.Books[0].Info.Title, res.Books[0].Info.Authors
+	}
+
+	return res
+}
@@ -1,14 +1,30 @@
 package factories
 
 import (
+	"context"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
 	"github.com/spf13/viper"
 )
 
 func NewViperCfg(cfgPath string, prefix string) *viper.Viper {
+	cfg, err := ReadViperCfg(cfgPath, prefix)
+	if err != nil {
+		panic(err)
+	}
+
+	return cfg
+}
+
+// ReadViperCfg reads the config file, settings of *.override.yml file and env variables.
+func ReadViperCfg(cfgPath string, prefix string) (*viper.Viper, error) {
 	cfg := viper.New()
 	cfg.SetEnvPrefix(prefix)
 	cfg.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -16,16 +32,16 @@ func NewViperCfg(cfgPath string, prefix string) *viper.Viper {
 	cfg.SetConfigFile(cfgPath)
 
 	if err := cfg.ReadInConfig(); err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	// override settings if *.override.yml exists
-	overrideCfgPath := strings.TrimSuffix(cfgPath, path.Ext(cfgPath)) + ".override" + path.Ext(cfgPath)
-	if _, fsErr := os.Stat(overrideCfgPath); fsErr == nil {
-		cfg.SetConfigFile(overrideCfgPath)
+	overridePath := overrideCfgPath(cfgPath)
+	if _, fsErr := os.Stat(overridePath); fsErr == nil {
+		cfg.SetConfigFile(overridePath)
 
 		if err := cfg.MergeInConfig(); err != nil {
-			panic(err)
+			return nil, err
 		}
 	}
 
@@ -35,5 +51,97 @@ func NewViperCfg(cfgPath string, prefix string) *viper.Viper {
 		cfg.Set(key, val)
 	}
 
-	return cfg
+	return cfg, nil
+}
+
+// WatchViperCfg reads the config again after the config file or its override file is changed or removed and passes
+// it to the handler. Changes are handled after the delay, so several writes of the files are handled once. The config
+// is not passed to the handler if it can't be read, the handler should keep the previous config if it returns error.
+// Directory of the config is watched, so files, which are removed or replaced by editors, are still watched.
+// viper.WatchConfig is not used: it watches the single config file, so the override file is not watched, it stops
+// watching after the file is removed, can't be stopped by the context and reads the file into the same viper, so
+// settings of the override file are lost and readers of the config see it half-updated.
+func WatchViperCfg(ctx context.Context, cfgPath string, prefix string, delay time.Duration, logger zerolog.Logger,
+	handler func(*viper.Viper) error,
+) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Error().Err(err).Msg("config watcher init failed")
+		return
+	}
+
+	if err = watcher.Add(filepath.Dir(cfgPath)); err != nil {
+		watcher.Close()
+		logger.Error().Err(err).Str("dir", filepath.Dir(cfgPath)).Msg("config watcher init failed")
+
+		return
+	}
+
+	var (
+		timer   *time.Timer
+		reloads sync.Mutex
+	)
+
+	reload := func(event fsnotify.Event) {
+		logger.Debug().Str("file", event.Name).Str("op", event.Op.String()).Msg("config changed")
+
+		if timer != nil {
+			timer.Stop()
+		}
+
+		timer = time.AfterFunc(delay, func() {
+			reloads.Lock()
+			defer reloads.Unlock()
+
+			cfg, err := ReadViperCfg(cfgPath, prefix)
+			if err == nil {
+				err = handler(cfg)
+			}
+
+			if err != nil {
+				logger.Error().Err(err).Str("file", event.Name).Msg("config reload failed, previous config is kept")
+				return
+			}
+
+			logger.Info().Str("file", event.Name).Msg("config reloaded")
+		})
+	}
+
+	files := map[string]struct{}{
+		filepath.Clean(cfgPath):                  {},
+		filepath.Clean(overrideCfgPath(cfgPath)): {},
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if _, watched := files[filepath.Clean(event.Name)]; watched && event.Op != fsnotify.Chmod {
+					reload(event)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				logger.Error().Err(err).Msg("config watcher error")
+			}
+		}
+	}()
+}
+
+func overrideCfgPath(cfgPath string) string {
+	return strings.TrimSuffix(cfgPath, path.Ext(cfgPath)) + ".override" + path.Ext(cfgPath)
 }
@@ -0,0 +1,57 @@
+package factories
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+
+	"github.com/egnd/fb2lib/internal/repos"
+	"github.com/egnd/fb2lib/pkg/metrics"
+)
+
+var (
+	storeSize = metrics.NewGauge("fb2lib_store_size_bytes", "Size of store files.", "store")
+	booksCnt  = metrics.NewGauge("fb2lib_books", "Count of indexed books.")
+)
+
+// RegisterStoreMetrics updates sizes of leveldb buckets and bleve index and count of books before metrics are
+// written.
+func RegisterStoreMetrics(cfg *viper.Viper, repo *repos.BooksLevelBleve) {
+	leveldbDir := cfg.GetString("adapters.leveldb.dir")
+	bleveDir := cfg.GetString("adapters.bleve.dir")
+
+	metrics.Default.OnCollect(func() {
+		if entries, err := os.ReadDir(leveldbDir); err == nil {
+			for _, entry := range entries {
+				if entry.IsDir() {
+					storeSize.Set(float64(dirSize(path.Join(leveldbDir, entry.Name()))), entry.Name())
+				}
+			}
+		}
+
+		storeSize.Set(float64(dirSize(bleveDir)), "index")
+
+		if cnt, err := repo.GetIndexedCnt(); err == nil {
+			booksCnt.Set(float64(cnt))
+		}
+	})
+}
+
+func dirSize(dir string) (res int64) {
+	_ = filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+
+		if finfo, err := entry.Info(); err == nil {
+			res += finfo.Size()
+		}
+
+		return nil
+	})
+
+	return
+}
@@ -1,13 +1,18 @@
 package factories
 
 import (
+	"context"
+	"crypto/subtle"
 	"net/http"
 	"path"
+	"strings"
 
 	"github.com/egnd/fb2lib/internal/entities"
 	"github.com/egnd/fb2lib/internal/handlers"
+	"github.com/egnd/fb2lib/internal/indexer"
 	"github.com/egnd/fb2lib/internal/repos"
 	"github.com/egnd/fb2lib/pkg/echoext"
+	"github.com/egnd/fb2lib/pkg/metrics"
 	"github.com/labstack/echo/v4"
 
 	"github.com/flosch/pongo2/v5"
@@ -16,8 +21,9 @@ import (
 	"github.com/spf13/viper"
 )
 
-func NewEchoServer(version string, libs entities.Libraries, cfg *viper.Viper, logger zerolog.Logger,
-	repoInfo *repos.BooksLevelBleve, repoBooks *repos.LibraryFs,
+func NewEchoServer(ctx context.Context, version string, libs entities.Libraries, cfg *viper.Viper, logger zerolog.Logger,
+	repoInfo *repos.BooksLevelBleve, repoBooks *repos.LibraryFs, repoFailures *repos.IndexFailures,
+	repoRuns *repos.IndexRuns, idx *indexer.Indexer,
 ) (*echo.Echo, error) {
 	var err error
 	server := echo.New()
@@ -30,6 +36,20 @@ func NewEchoServer(version string, libs entities.Libraries, cfg *viper.Viper, lo
 	}
 
 	server.Use(echoext.NewZeroLogger(cfg, logger))
+	server.Use(httpMetrics)
+	if adminEnabled(cfg) {
+		// admin forms are rendered at public pages too, so the token is issued for all pages
+		server.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
+			Skipper: func(c echo.Context) bool {
+				return strings.HasPrefix(c.Request().URL.Path, "/assets/") || c.Request().URL.Path == "/metrics"
+			},
+			TokenLookup:    "form:" + echoext.CSRFField,
+			ContextKey:     echoext.CSRFField,
+			CookiePath:     "/",
+			CookieHTTPOnly: true,
+			CookieSameSite: http.SameSiteStrictMode,
+		}))
+	}
 	if server.Debug {
 		echoext.AddPprofHandlers(server)
 	} else {
@@ -41,6 +61,7 @@ func NewEchoServer(version string, libs entities.Libraries, cfg *viper.Viper, lo
 	server.GET("/live", func(c echo.Context) error {
 		return c.String(http.StatusOK, "OK")
 	})
+	server.GET("/metrics", echo.WrapHandler(metrics.Default))
 
 	server.GET("/", func(c echo.Context) error { return c.Redirect(http.StatusMovedPermanently, "/books/") })
 	server.GET("/books/", handlers.BooksHandler(cfg, libs, repoInfo, repoBooks, logger))
@@ -48,16 +69,56 @@ func NewEchoServer(version string, libs entities.Libraries, cfg *viper.Viper, lo
 	server.GET("/download/:book", handlers.DownloadHandler(libs, repoInfo, cfg, logger))
 	server.GET("/book/:id", handlers.BookDetailsHandler(repoInfo, repoBooks))
 	server.GET("/book/:id/remove", handlers.RemoveBookHandler(repoInfo))
+	server.GET("/isbn/:isbn", handlers.ISBNHandler(repoInfo))
+	server.GET("/work/:id", handlers.WorkHandler(repoInfo, repoBooks))
 	server.GET("/genres/", handlers.GenresHandler(cfg, repoInfo))
-	server.GET("/series/", handlers.SeriesHandler(cfg, repoInfo))
-	server.GET("/series/:letter/", handlers.SeriesHandler(cfg, repoInfo))
+	server.GET("/tags/", handlers.TagsHandler(cfg, repoInfo))
+	server.GET("/series/", handlers.SeriesHandler(cfg, repoInfo, repoBooks))
+	server.GET("/series/:letter/", handlers.SeriesHandler(cfg, repoInfo, repoBooks))
+	server.GET("/series/:letter/:name", handlers.SeriesHandler(cfg, repoInfo, repoBooks))
 	server.GET("/authors/", handlers.AuthorsHandler(cfg, repoInfo, repoBooks))
 	server.GET("/authors/:letter/", handlers.AuthorsHandler(cfg, repoInfo, repoBooks))
 	server.GET("/authors/:letter/:name", handlers.AuthorsHandler(cfg, repoInfo, repoBooks))
 
+	if !adminEnabled(cfg) {
+		logger.Warn().Msg("admin pages are disabled, set both server.admin.user and server.admin.password to enable them")
+		return server, nil
+	}
+
+	user := cfg.GetString("server.admin.user")
+	password := cfg.GetString("server.admin.password")
+	admin := server.Group("/admin", middleware.BasicAuth(func(reqUser, reqPassword string, c echo.Context) (bool, error) {
+		return subtle.ConstantTimeCompare([]byte(reqUser), []byte(user)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(reqPassword), []byte(password)) == 1, nil
+	}))
+
+	admin.POST("/authors/merge", handlers.MergeAuthorsHandler(repoInfo))
+	admin.POST("/authors/split", handlers.SplitAuthorHandler(repoInfo))
+	admin.GET("/failures/", handlers.FailuresHandler(cfg, repoFailures))
+	admin.GET("/runs/", handlers.RunsHandler(cfg, repoRuns))
+	admin.GET("/runs/:id", handlers.RunHandler(repoRuns))
+	admin.GET("/indexing/", handlers.IndexingHandler(libs, idx))
+	admin.GET("/indexing/events", handlers.IndexingEventsHandler(ctx, idx))
+	admin.POST("/indexing/start", handlers.IndexingStartHandler(ctx, cfg, idx, repoInfo, logger))
+	admin.POST("/indexing/pause", handlers.IndexingPauseHandler(idx))
+	admin.POST("/indexing/resume", handlers.IndexingResumeHandler(idx))
+	admin.POST("/indexing/cancel", handlers.IndexingCancelHandler(idx))
+
 	return server, nil
 }
 
+// adminEnabled reports whether admin pages are served: an empty user or password would let anyone in.
+func adminEnabled(cfg *viper.Viper) bool {
+	return cfg.GetString("server.admin.user") != "" && cfg.GetString("server.admin.password") != ""
+}
+
+var templateCacheRequests = metrics.NewCounter("fb2lib_template_cache_requests_total",
+	"Count of templates cache requests by result (hit or miss).", "result",
+)
+
+// httpMetrics is shared by servers, which are built again after config reload, metrics are registered once.
+var httpMetrics = echoext.NewMetricsMiddleware(metrics.Default, "fb2lib")
+
 func NewEchoRender(version string, cfg *viper.Viper,
 	server *echo.Echo, repo *repos.BooksLevelBleve, logger zerolog.Logger,
 ) (echo.Renderer, error) {
@@ -65,6 +126,7 @@ func NewEchoRender(version string, cfg *viper.Viper,
 	genres, _ := repo.GetCnt(repos.BucketGenres)
 	authors, _ := repo.GetCnt(repos.BucketAuthors)
 	series, _ := repo.GetCnt(repos.BucketSeries)
+	tags, _ := repo.GetCnt(repos.BucketTags)
 
 	globals := cfg.GetStringMap("renderer.globals")
 	globals["sidebar_stats"] = map[string]uint64{
@@ -72,17 +134,42 @@ func NewEchoRender(version string, cfg *viper.Viper,
 		"authors": authors,
 		"genres":  genres,
 		"series":  series,
+		"tags":    tags,
 	}
 	globals["libslist"], _ = repo.GetLibs()
 	globals["langslist"], _ = repo.GetLangs()
 	globals["app_version"] = version
 	globals["debug"] = server.Debug
+	globals["admin"] = adminEnabled(cfg)
 
 	return echoext.NewPongoRenderer(echoext.PongoRendererCfg{
-		Debug:   server.Debug,
-		TplsDir: cfg.GetString("renderer.dir"),
+		Debug:         server.Debug,
+		TplsDir:       cfg.GetString("renderer.dir"),
+		CacheRequests: templateCacheRequests,
 	}, globals, map[string]pongo2.FilterFunction{
 		"filesize":  echoext.PongoFilterFileSize,
 		"trimspace": echoext.PongoFilterTrimSpace,
+		"genre":     NewPongoFilterGenre(cfg.GetString("renderer.lang")),
+		"lang":      NewPongoFilterLang(cfg.GetString("renderer.lang")),
+		"annot":     PongoFilterAnnotation,
 	})
 }
+
+// PongoFilterAnnotation sanitizes annotation at render time, so books indexed before annotations sanitizing are safe too.
+func PongoFilterAnnotation(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+	res, _ := entities.SanitizeAnnotation(in.String())
+
+	return pongo2.AsSafeValue(res), nil
+}
+
+func NewPongoFilterGenre(lang string) pongo2.FilterFunction {
+	return func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return pongo2.AsValue(entities.GetGenreTitle(in.String(), lang)), nil
+	}
+}
+
+func NewPongoFilterLang(lang string) pongo2.FilterFunction {
+	return func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return pongo2.AsValue(entities.GetLangTitle(in.String(), lang)), nil
+	}
+}
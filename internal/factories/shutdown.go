@@ -0,0 +1,32 @@
+package factories
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog"
+)
+
+// NewShutdownContext returns context, which is canceled after SIGINT or SIGTERM. The second signal kills the
+// process as usual.
+func NewShutdownContext(logger zerolog.Logger) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		defer signal.Stop(signals)
+
+		select {
+		case sig := <-signals:
+			logger.Warn().Str("signal", sig.String()).Msg("shutting down")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
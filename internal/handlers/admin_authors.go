@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/repos"
+	"github.com/labstack/echo/v4"
+)
+
+func MergeAuthorsHandler(repo *repos.BooksLevelBleve) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		var target *entities.Author
+		if target, err = repo.GetAuthorByName(c.FormValue("to")); err != nil || target == nil {
+			c.NoContent(http.StatusBadRequest)
+			return
+		}
+
+		if target, err = repo.MergeAuthors(c.FormValue("from"), target.ID); err != nil {
+			c.NoContent(http.StatusBadRequest)
+			return
+		}
+
+		return c.Redirect(http.StatusSeeOther, buildAuthorURL(target))
+	}
+}
+
+func SplitAuthorHandler(repo *repos.BooksLevelBleve) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		var author *entities.Author
+		if author, err = repo.SplitAuthor(c.FormValue("id"), c.FormValue("key")); err != nil {
+			c.NoContent(http.StatusBadRequest)
+			return
+		}
+
+		return c.Redirect(http.StatusSeeOther, buildAuthorURL(author))
+	}
+}
+
+func buildAuthorURL(author *entities.Author) string {
+	if author.Val == "" {
+		return "/authors/"
+	}
+
+	return "/authors/" + url.PathEscape(strings.ToUpper(string([]rune(author.Val)[0:1]))) + "/" + url.PathEscape(author.Val)
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/repos"
+	"github.com/egnd/fb2lib/pkg/pagination"
+	"github.com/flosch/pongo2/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+)
+
+func FailuresHandler(cfg *viper.Viper, repo *repos.IndexFailures) echo.HandlerFunc {
+	defPageSize := cfg.GetInt("renderer.failures_size")
+
+	return func(c echo.Context) (err error) {
+		filter := entities.IndexFailuresFilter{
+			Lib:   c.QueryParam("lib"),
+			Stage: c.QueryParam("stage"),
+			Query: c.QueryParam("q"),
+		}
+
+		pager := pagination.NewPager(c.Request()).SetPageSize(defPageSize).ReadPageSize().ReadCurPage()
+
+		failures, err := repo.Find(filter, pager)
+		if err != nil {
+			c.NoContent(http.StatusInternalServerError)
+			return
+		}
+
+		return c.Render(http.StatusOK, "pages/failures.html", pongo2.Context{
+			"section_name": "failures",
+			"page_title":   "Ошибки индексации",
+			"page_h1":      "Ошибки индексации",
+
+			"failures":    failures,
+			"filter":      filter,
+			"stages":      []string{entities.FailureStageDefine, entities.FailureStageRead, entities.FailureStageParse, entities.FailureStageSkip},
+			"breadcrumbs": (entities.BreadCrumbs{}).Push("Ошибки индексации", ""),
+			"pager":       pager,
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/indexer"
+	"github.com/egnd/fb2lib/internal/repos"
+	"github.com/flosch/pongo2/v5"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+const indexingEventsInterval = time.Second
+
+func IndexingHandler(libs entities.Libraries, idx *indexer.Indexer) echo.HandlerFunc {
+	var libsNames []string
+
+	for name, lib := range libs {
+		if !lib.Disabled {
+			libsNames = append(libsNames, name)
+		}
+	}
+
+	sort.Strings(libsNames)
+
+	return func(c echo.Context) error {
+		return c.Render(http.StatusOK, "pages/indexing.html", pongo2.Context{
+			"section_name": "indexing",
+			"page_title":   "Индексация",
+			"page_h1":      "Индексация",
+
+			"libs":        libsNames,
+			"progress":    idx.Progress(),
+			"breadcrumbs": (entities.BreadCrumbs{}).Push("Индексация", ""),
+		})
+	}
+}
+
+// IndexingEventsHandler streams indexing progress as server-sent events until the client is disconnected or the
+// server is shutting down.
+func IndexingEventsHandler(ctx context.Context, idx *indexer.Indexer) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+		c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
+		c.Response().WriteHeader(http.StatusOK)
+
+		ticker := time.NewTicker(indexingEventsInterval)
+		defer ticker.Stop()
+
+		for {
+			data, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(idx.Progress())
+			if err != nil {
+				return err
+			}
+
+			if _, err = fmt.Fprintf(c.Response(), "event: progress\ndata: %s\n\n", data); err != nil {
+				return nil
+			}
+
+			c.Response().Flush()
+
+			select {
+			case <-c.Request().Context().Done():
+				return nil
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// IndexingStartHandler starts indexing of the library or of all libraries, summary is rebuilt after indexing.
+// Indexing is canceled with the server context.
+func IndexingStartHandler(ctx context.Context, cfg *viper.Viper, idx *indexer.Indexer, repo *repos.BooksLevelBleve,
+	logger zerolog.Logger,
+) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		lib := c.FormValue("lib")
+
+		err = idx.Start(ctx, lib, func(err error) {
+			if err != nil {
+				logger.Warn().Err(err).Str("lib", lib).Msg("indexing stopped")
+			}
+
+			progress := idx.Progress()
+			logger.Info().Str("lib", lib).Uint32("succeed", progress.Books).Uint32("failed", progress.Failures).
+				Dur("dur", progress.Elapsed).Msg("indexing finished")
+
+			indexer.RefreshSummary(ctx, repo, cfg.GetInt("indexer.batch_size"), logger)
+		})
+
+		switch {
+		case errors.Is(err, indexer.ErrRunning):
+			c.NoContent(http.StatusConflict)
+			return
+		case err != nil:
+			c.NoContent(http.StatusBadRequest)
+			return
+		}
+
+		logger.Info().Str("lib", lib).Msg("indexing started")
+
+		return c.Redirect(http.StatusSeeOther, "/admin/indexing/")
+	}
+}
+
+func IndexingPauseHandler(idx *indexer.Indexer) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		idx.Pause()
+
+		return c.Redirect(http.StatusSeeOther, "/admin/indexing/")
+	}
+}
+
+func IndexingResumeHandler(idx *indexer.Indexer) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		idx.Resume()
+
+		return c.Redirect(http.StatusSeeOther, "/admin/indexing/")
+	}
+}
+
+func IndexingCancelHandler(idx *indexer.Indexer) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		idx.Cancel()
+
+		return c.Redirect(http.StatusSeeOther, "/admin/indexing/")
+	}
+}
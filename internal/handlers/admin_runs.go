@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/repos"
+	"github.com/egnd/fb2lib/pkg/pagination"
+	"github.com/flosch/pongo2/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+)
+
+func RunsHandler(cfg *viper.Viper, repo *repos.IndexRuns) echo.HandlerFunc {
+	defPageSize := cfg.GetInt("renderer.runs_size")
+
+	return func(c echo.Context) (err error) {
+		pager := pagination.NewPager(c.Request()).SetPageSize(defPageSize).ReadPageSize().ReadCurPage()
+
+		runs, err := repo.Find(pager)
+		if err != nil {
+			c.NoContent(http.StatusInternalServerError)
+			return
+		}
+
+		return c.Render(http.StatusOK, "pages/runs.html", pongo2.Context{
+			"section_name": "runs",
+			"page_title":   "История индексации",
+			"page_h1":      "История индексации",
+
+			"runs":        runs,
+			"breadcrumbs": (entities.BreadCrumbs{}).Push("История индексации", ""),
+			"pager":       pager,
+		})
+	}
+}
+
+// RunHandler returns JSON report of the run.
+func RunHandler(repo *repos.IndexRuns) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		run, err := repo.Get(c.Param("id"))
+		if err != nil {
+			c.NoContent(http.StatusNotFound)
+			return
+		}
+
+		return c.JSONPretty(http.StatusOK, run, "  ")
+	}
+}
@@ -35,6 +35,7 @@ func AuthorsHandler(cfg *viper.Viper,
 		pager := pagination.NewPager(c.Request()).SetPageSize(defPageSize).ReadPageSize().ReadCurPage()
 
 		var title string
+		var author *entities.Author
 		var books []entities.Book
 		var series entities.FreqsItems
 		var breadcrumbs entities.BreadCrumbs
@@ -48,6 +49,11 @@ func AuthorsHandler(cfg *viper.Viper,
 		if name != "" {
 			title = "Автор " + name
 
+			if author, err = repoInfo.GetAuthorByName(name); err != nil {
+				c.NoContent(http.StatusInternalServerError)
+				return err
+			}
+
 			books, err = repoInfo.GetAuthorsBooks(500, []string{name}, nil)
 			if err != nil {
 				c.NoContent(http.StatusInternalServerError)
@@ -87,6 +93,7 @@ func AuthorsHandler(cfg *viper.Viper,
 
 			"cur_letter":  letter,
 			"cur_name":    name,
+			"author":      author,
 			"authors":     authors,
 			"series":      series,
 			"books":       books,
@@ -25,6 +25,35 @@ func BookDetailsHandler(
 			return
 		}
 
+		var alternates []entities.Book
+		if alternates, err = repoBooks.GetExistingByIDs(book.Alternates); err != nil {
+			c.NoContent(http.StatusInternalServerError)
+			return
+		}
+
+		var dupMain *entities.Book
+		if book.DupOf != "" {
+			var mains []entities.Book
+			if mains, err = repoBooks.GetExistingByIDs([]string{book.DupOf}); err != nil {
+				c.NoContent(http.StatusInternalServerError)
+				return
+			}
+
+			if len(mains) > 0 {
+				dupMain = &mains[0]
+			}
+		}
+
+		workID := book.WorkID(repoBooks.ResolveAuthorKey)
+
+		var editions []entities.Book
+		if editions, err = repoBooks.GetWorkBooks(100, workID, book); err != nil {
+			c.NoContent(http.StatusInternalServerError)
+			return
+		}
+
+		repoLib.AppendFB2Books(editions)
+
 		var seriesBooks, authorsBooks []entities.Book
 		var series entities.FreqsItems
 
@@ -51,6 +80,10 @@ func BookDetailsHandler(
 			"page_title":     "Книга " + book.Info.Title,
 			"page_h1":        book.Info.Title,
 			"book":           book,
+			"alternates":     alternates,
+			"dup_main":       dupMain,
+			"editions":       editions,
+			"work_id":        workID,
 			"series_books":   seriesBooks,
 			"authors_books":  authorsBooks,
 			"authors_series": series,
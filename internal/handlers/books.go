@@ -26,6 +26,8 @@ func BooksHandler(cfg *viper.Viper, libs entities.Libraries,
 		panic(err)
 	}
 
+	lang := cfg.GetString("renderer.lang")
+
 	return func(c echo.Context) (err error) {
 		tag, err := url.PathUnescape(c.Param("tag"))
 		if err != nil {
@@ -43,9 +45,21 @@ func BooksHandler(cfg *viper.Viper, libs entities.Libraries,
 		pager := pagination.NewPager(c.Request()).SetPageSize(defPageSize).ReadPageSize().ReadCurPage()
 		title := "Поиск по книгам"
 
+		tagTitle := tagValue
+		switch entities.IndexField(tag) {
+		case entities.IdxFGenre:
+			tagTitle = entities.GetGenreTitle(tagValue, lang)
+		case entities.IdxFLang:
+			if code, ok := entities.NormalizeLang(tagValue); ok {
+				tagValue = code
+			}
+
+			tagTitle = entities.GetLangTitle(tagValue, lang)
+		}
+
 		var breadcrumbs entities.BreadCrumbs
 		if tagValue != "" {
-			breadcrumbs = breadcrumbs.Push("Книги", "/books/").Push(tagValue, "")
+			breadcrumbs = breadcrumbs.Push("Книги", "/books/").Push(tagTitle, "")
 		} else {
 			breadcrumbs = breadcrumbs.Push("Книги", "")
 		}
@@ -58,13 +72,15 @@ func BooksHandler(cfg *viper.Viper, libs entities.Libraries,
 		case entities.IdxFSerie:
 			title += fmt.Sprintf(` серии "%s"`, tagValue)
 		case entities.IdxFGenre:
-			title += fmt.Sprintf(` в жанре "%s"`, tagValue)
+			title += fmt.Sprintf(` в жанре "%s"`, tagTitle)
 		case entities.IdxFPublisher:
 			title += fmt.Sprintf(` издателя "%s"`, tagValue)
 		case entities.IdxFLang:
-			title += fmt.Sprintf(` на языке %s`, tagValue)
+			title += fmt.Sprintf(` на языке "%s"`, tagTitle)
 		case entities.IdxFLib:
 			title += fmt.Sprintf(` в коллекции "%s"`, tagValue)
+		case entities.IdxFKeywords:
+			title += fmt.Sprintf(` с ключевым словом "%s"`, tagValue)
 		}
 
 		var books []entities.Book
@@ -40,14 +40,19 @@ func DownloadHandler(libs entities.Libraries,
 			return
 		}
 
+		archive, _ := book.Archive()
+
 		switch {
-		case bookType == "fb2" && path.Ext(book.Src) == ".fb2" && strings.Contains(book.Src, ".zip"):
-			err = response.FB2FromLocalZip(book, libs, c)
-		case bookType == "fb2" && path.Ext(book.Src) == ".fb2":
+		case book.Format() == entities.BookFormatEPUB && bookType != entities.BookFormatEPUB:
+			c.NoContent(http.StatusNotFound)
+			return
+		case bookType == book.Format() && archive != "":
+			err = response.BookFromLocalArchive(book, libs, c)
+		case bookType == book.Format():
 			err = response.BookAttachment(book, libs, c)
-		case bookType == "epub" && path.Ext(book.Src) == ".fb2" && strings.Contains(book.Src, ".zip"):
-			err = response.ConvertFB2EpubZipOffset(converterDir, book, libs, c, logger)
-		case bookType == "epub" && path.Ext(book.Src) == ".fb2":
+		case bookType == entities.BookFormatEPUB && archive != "":
+			err = response.ConvertFB2EpubArchived(converterDir, book, libs, c, logger)
+		case bookType == entities.BookFormatEPUB && path.Ext(book.Src) == ".fb2":
 			err = response.ConvertFB2Epub(converterDir, book, libs, c, logger)
 		default:
 			err = fmt.Errorf("download %s book error: invalid src %s", bookType, book.Src)
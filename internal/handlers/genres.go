@@ -5,19 +5,22 @@ import (
 
 	"github.com/egnd/fb2lib/internal/entities"
 	"github.com/egnd/fb2lib/internal/repos"
-	"github.com/egnd/fb2lib/pkg/pagination"
 	"github.com/flosch/pongo2/v5"
 	"github.com/labstack/echo/v4"
 	"github.com/spf13/viper"
 )
 
 func GenresHandler(cfg *viper.Viper, repo *repos.BooksLevelBleve) echo.HandlerFunc {
-	defPageSize := cfg.GetInt("renderer.globals.genres_size")
+	lang := cfg.GetString("renderer.lang")
 
 	return func(c echo.Context) (err error) {
-		pager := pagination.NewPager(c.Request()).SetPageSize(defPageSize).ReadPageSize().ReadCurPage()
+		genres, err := repo.GetGenres(nil)
+		if err != nil {
+			c.NoContent(http.StatusBadRequest)
+			return
+		}
 
-		genres, err := repo.GetGenres(pager)
+		cats, err := repo.GetGenreCats()
 		if err != nil {
 			c.NoContent(http.StatusBadRequest)
 			return
@@ -28,8 +31,7 @@ func GenresHandler(cfg *viper.Viper, repo *repos.BooksLevelBleve) echo.HandlerFu
 			"page_title":   "Список жанров",
 			"page_h1":      "Список жанров",
 
-			"genres":      genres,
-			"pager":       pager,
+			"genres":      entities.NewGenresTree(genres, cats, lang),
 			"breadcrumbs": (entities.BreadCrumbs{}).Push("Жанры", ""),
 		})
 	}
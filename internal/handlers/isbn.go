@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/repos"
+	"github.com/egnd/fb2lib/pkg/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+func ISBNHandler(repo *repos.BooksLevelBleve) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		isbn, err := url.PathUnescape(c.Param("isbn"))
+		if err != nil || entities.CleanISBN(isbn) == "" {
+			c.NoContent(http.StatusBadRequest)
+			return
+		}
+
+		if parsed, parseErr := entities.ParseISBN(isbn); parseErr == nil {
+			isbn = parsed.ISBN13
+		} else {
+			isbn = entities.CleanISBN(isbn)
+		}
+
+		pager := pagination.NewPager(c.Request()).SetPageSize(2)
+
+		books, err := repo.FindBooks("", entities.IdxFISBN, isbn, pager)
+		if err != nil {
+			c.NoContent(http.StatusInternalServerError)
+			return
+		}
+
+		// only duplicates could have the isbn, the main book of the first one is shown then, because duplicates are
+		// hidden from the books list
+		if len(books) == 0 {
+			if books, err = repo.GetISBNDupsMains(1, isbn); err != nil {
+				c.NoContent(http.StatusInternalServerError)
+				return
+			}
+		}
+
+		switch len(books) {
+		case 0:
+			return c.NoContent(http.StatusNotFound)
+		case 1:
+			return c.Redirect(http.StatusFound, "/book/"+books[0].ID)
+		default:
+			return c.Redirect(http.StatusFound, "/books/"+string(entities.IdxFISBN)+"/"+url.PathEscape(isbn)+"/")
+		}
+	}
+}
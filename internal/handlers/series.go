@@ -3,6 +3,7 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/egnd/fb2lib/internal/entities"
 	"github.com/egnd/fb2lib/internal/repos"
@@ -12,11 +13,22 @@ import (
 	"github.com/spf13/viper"
 )
 
-func SeriesHandler(cfg *viper.Viper, repo *repos.BooksLevelBleve) echo.HandlerFunc {
+func SeriesHandler(cfg *viper.Viper, repo *repos.BooksLevelBleve, repoBooks *repos.LibraryFs) echo.HandlerFunc {
 	defPageSize := cfg.GetInt("renderer.globals.series_size")
 
 	return func(c echo.Context) (err error) {
-		letter := c.Param("letter")
+		letter, err := url.QueryUnescape(c.Param("letter"))
+		if err != nil {
+			c.NoContent(http.StatusBadRequest)
+			return
+		}
+
+		name, err := url.QueryUnescape(c.Param("name"))
+		if err != nil {
+			c.NoContent(http.StatusBadRequest)
+			return
+		}
+
 		pager := pagination.NewPager(c.Request()).SetPageSize(defPageSize).ReadPageSize().ReadCurPage()
 
 		series, err := repo.GetSeriesByPrefix(letter, pager)
@@ -25,12 +37,27 @@ func SeriesHandler(cfg *viper.Viper, repo *repos.BooksLevelBleve) echo.HandlerFu
 			return
 		}
 
+		var volumes []entities.SerieVolume
 		var breadcrumbs entities.BreadCrumbs
 		title := "Книжные серии"
-		if letter != "" {
+
+		switch {
+		case name != "":
+			title = fmt.Sprintf(`Серия "%s"`, name)
+			breadcrumbs = breadcrumbs.Push("Cерии", "/series/").Push(letter, "/series/"+url.PathEscape(letter)+"/").Push(name, "")
+
+			var books []entities.Book
+			if books, err = repo.GetSerieBooks(500, name); err != nil {
+				c.NoContent(http.StatusInternalServerError)
+				return
+			}
+
+			repoBooks.AppendFB2Books(books)
+			volumes = entities.NewSerieVolumes(name, books)
+		case letter != "":
 			title += fmt.Sprintf(`, начинающиеся с "%s"`, letter)
 			breadcrumbs = breadcrumbs.Push("Cерии", "/series/").Push(letter, "")
-		} else {
+		default:
 			breadcrumbs = breadcrumbs.Push("Cерии", "")
 		}
 
@@ -40,7 +67,9 @@ func SeriesHandler(cfg *viper.Viper, repo *repos.BooksLevelBleve) echo.HandlerFu
 			"page_h1":      title,
 
 			"cur_letter":  letter,
+			"cur_name":    name,
 			"series":      series,
+			"volumes":     volumes,
 			"breadcrumbs": breadcrumbs,
 			"pager":       pager,
 		})
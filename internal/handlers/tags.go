@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/repos"
+	"github.com/flosch/pongo2/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+)
+
+func TagsHandler(cfg *viper.Viper, repo *repos.BooksLevelBleve) echo.HandlerFunc {
+	limit := cfg.GetInt("renderer.tags_size")
+
+	return func(c echo.Context) (err error) {
+		tags, err := repo.GetTags(limit)
+		if err != nil {
+			c.NoContent(http.StatusBadRequest)
+			return
+		}
+
+		return c.Render(http.StatusOK, "pages/tags.html", pongo2.Context{
+			"section_name": "tags",
+			"page_title":   "Ключевые слова",
+			"page_h1":      "Ключевые слова",
+
+			"tags":        tags,
+			"breadcrumbs": (entities.BreadCrumbs{}).Push("Ключевые слова", ""),
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/repos"
+	"github.com/flosch/pongo2/v5"
+	"github.com/labstack/echo/v4"
+)
+
+func WorkHandler(repoBooks *repos.BooksLevelBleve, repoLib *repos.LibraryFs) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		books, err := repoBooks.GetWorkBooks(1000, c.Param("id"), nil)
+		if err != nil {
+			c.NoContent(http.StatusInternalServerError)
+			return
+		}
+
+		if len(books) == 0 {
+			c.NoContent(http.StatusNotFound)
+			return
+		}
+
+		repoLib.AppendFB2Books(books)
+
+		work := entities.NewWork(c.Param("id"), books)
+
+		return c.Render(http.StatusOK, "pages/work.html", pongo2.Context{
+			"page_title":  "Издания " + work.Title,
+			"page_h1":     work.Title,
+			"work":        work,
+			"breadcrumbs": (entities.BreadCrumbs{}).Push("Книги", "/books/").Push(work.Title, ""),
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package indexer
+
+import (
+	"sync"
+)
+
+// checkpoints tracks entries of the archive in walking order. When entries are processed up to some one, its
+// offset is passed to the save callback, the last call receives complete flag after all entries are processed.
+type checkpoints struct {
+	mu      sync.Mutex
+	offsets []uint64
+	done    map[uint64]bool
+	next    int
+	walked  bool
+	save    func(offset uint64, complete bool)
+}
+
+func newCheckpoints(save func(offset uint64, complete bool)) *checkpoints {
+	return &checkpoints{
+		done: map[uint64]bool{},
+		save: save,
+	}
+}
+
+// add registers the entry before it is pushed to processing.
+func (c *checkpoints) add(offset uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.offsets = append(c.offsets, offset)
+}
+
+// finish is called after the archive is walked, the archive is complete if all entries were pushed.
+func (c *checkpoints) finish(walked bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.walked = walked
+
+	if walked && c.next == len(c.offsets) {
+		c.save(c.last(), true)
+	}
+}
+
+// processed marks the entry as processed and saves the new checkpoint if the first unprocessed entry has changed.
+func (c *checkpoints) processed(offset uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.done[offset] = true
+
+	prev := c.next
+	for c.next < len(c.offsets) && c.done[c.offsets[c.next]] {
+		delete(c.done, c.offsets[c.next])
+		c.next++
+	}
+
+	if c.next != prev {
+		c.save(c.last(), c.walked && c.next == len(c.offsets))
+	}
+}
+
+func (c *checkpoints) last() uint64 {
+	if c.next == 0 {
+		return 0
+	}
+
+	return c.offsets[c.next-1]
+}
@@ -0,0 +1,711 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/egnd/go-pipeline"
+	"github.com/egnd/go-pipeline/pools"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+	"github.com/vbauerster/mpb/v7"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/repos"
+	"github.com/egnd/fb2lib/internal/tasks"
+)
+
+var (
+	ErrCanceled     = errors.New("indexing canceled")
+	ErrRunning      = errors.New("indexing is running already")
+	ErrGraceTimeout = errors.New("indexing is not finished in grace timeout")
+)
+
+// Indexer runs library items through define, read and parse pools, failed items are memorized to retry them later.
+type Indexer struct {
+	wg           sync.WaitGroup
+	libsMu       sync.RWMutex
+	libs         entities.Libraries
+	rules        *entities.IndexRules
+	detectLang   bool
+	graceTimeout time.Duration
+	repoBooks    *repos.BooksLevelBleve
+	repoMarks    *repos.LibMarks
+	repoFailures *repos.IndexFailures
+	repoRuns     *repos.IndexRuns
+	bars         *mpb.Progress
+	progress     *Progress
+	logger       zerolog.Logger
+	pipe         pipeline.Dispatcher
+	readingPool  pipeline.Dispatcher
+	parsingPool  pipeline.Dispatcher
+	cntTotal     entities.CntAtomic32
+	cntIndexed   entities.CntAtomic32
+	mu           sync.Mutex
+	running      bool
+	canceled     bool
+	resume       chan struct{}
+	runs         sync.WaitGroup
+	abandoned    bool
+	entries      sync.Map
+	reportMu     sync.Mutex
+	report       *entities.IndexRun
+}
+
+func NewIndexer(cfg *viper.Viper, libs entities.Libraries,
+	repoBooks *repos.BooksLevelBleve, repoMarks *repos.LibMarks, repoFailures *repos.IndexFailures,
+	repoRuns *repos.IndexRuns, bars *mpb.Progress, bar *mpb.Bar, logger zerolog.Logger,
+) (*Indexer, error) {
+	rules, err := entities.NewIndexRules("indexer.rules", cfg, libs)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &Indexer{
+		libs:         libs,
+		rules:        rules,
+		detectLang:   cfg.GetBool("indexer.detect_lang"),
+		graceTimeout: cfg.GetDuration("shutdown.timeout"),
+		repoBooks:    repoBooks,
+		repoMarks:    repoMarks,
+		repoFailures: repoFailures,
+		repoRuns:     repoRuns,
+		bars:         bars,
+		progress:     NewProgress(bar),
+		logger:       logger,
+	}
+
+	if idx.graceTimeout == 0 {
+		idx.graceTimeout = 30 * time.Second
+	}
+
+	threads := cfg.GetInt("indexer.threads_cnt")
+	if threads == 0 {
+		threads = 1
+	}
+
+	readThreads := cfg.GetInt("indexer.read_threads")
+	if readThreads == 0 {
+		readThreads = threads * 10
+	}
+
+	parseThreads := cfg.GetInt("indexer.parse_threads")
+	if parseThreads == 0 {
+		if parseThreads = runtime.NumCPU() / 2; parseThreads == 0 {
+			parseThreads = 1
+		}
+	}
+
+	idx.pipe = pools.NewSemaphore(threads, &idx.wg, idx.defineMiddleware)
+	idx.readingPool = pools.NewBusPool(readThreads, cfg.GetInt("indexer.read_buff"), &idx.wg, idx.readMiddleware)
+	idx.parsingPool = pools.NewBusPool(parseThreads, cfg.GetInt("indexer.parse_buff"), &idx.wg, idx.parseMiddleware)
+
+	return idx, nil
+}
+
+func (i *Indexer) defineMiddleware(next pipeline.TaskExecutor) pipeline.TaskExecutor {
+	return func(task pipeline.Task) error {
+		i.logger.Debug().Str("task", task.ID()).Msg("iterate")
+		defer i.progress.incFiles()
+		defer itemsProcessed.Inc()
+
+		if err := next(task); err != nil {
+			var indexed *tasks.ErrAlreadyIndexed
+			switch {
+			case errors.As(err, &indexed), errors.Is(err, ErrCanceled):
+				i.logger.Info().Str("task", task.ID()).Msg(err.Error())
+			default:
+				i.logger.Error().Str("task", task.ID()).Err(err).Msg("iterate")
+				i.trackFailure(task, entities.FailureStageDefine, err)
+				i.countBook(task, (*entities.IndexRun).Failed)
+			}
+		} else {
+			i.trackFailure(task, entities.FailureStageDefine, nil)
+		}
+
+		return nil
+	}
+}
+
+func (i *Indexer) readMiddleware(next pipeline.TaskExecutor) pipeline.TaskExecutor {
+	return func(task pipeline.Task) error {
+		i.logger.Debug().Str("task", task.ID()).Msg("read")
+
+		if err := next(task); err != nil {
+			i.logger.Error().Str("task", task.ID()).Err(err).Msg("read")
+			i.trackFailure(task, entities.FailureStageRead, err)
+			i.countBook(task, (*entities.IndexRun).Failed)
+			i.entryProcessed(task)
+		}
+
+		return nil
+	}
+}
+
+func (i *Indexer) parseMiddleware(next pipeline.TaskExecutor) pipeline.TaskExecutor {
+	return func(task pipeline.Task) error {
+		i.logger.Debug().Str("task", task.ID()).Msg("parse")
+
+		start := time.Now()
+		err := next(task)
+		parseTime.ObserveSince(start)
+
+		if err != nil {
+			var skipRule *tasks.ErrSkipRule
+			if errors.As(err, &skipRule) {
+				i.logger.Warn().Str("task", task.ID()).Msg(err.Error())
+				i.trackFailure(task, entities.FailureStageSkip, err)
+			} else {
+				i.logger.Error().Str("task", task.ID()).Err(err).Msg("parse")
+				i.trackFailure(task, entities.FailureStageParse, err)
+			}
+
+			i.countSkipped(task, err)
+		} else {
+			i.cntIndexed.Inc(1)
+			i.progress.incBooks()
+			booksIndexed.Inc()
+			i.trackFailure(task, entities.FailureStageParse, nil)
+			i.countBook(task, (*entities.IndexRun).Indexed)
+		}
+
+		i.entryProcessed(task)
+
+		return nil
+	}
+}
+
+// trackFailure memorizes failed items and forgets them after success, define stage success forgets define
+// failures only, because items are still processed at next stages.
+func (i *Indexer) trackFailure(task pipeline.Task, stage string, err error) {
+	item, ok := task.(tasks.FailedItem)
+	if !ok {
+		return
+	}
+
+	book := item.FailedItem()
+
+	switch {
+	case err == nil && stage == entities.FailureStageDefine:
+		err = i.repoFailures.Remove(book.Lib, book.Src, stage)
+	case err == nil:
+		err = i.repoFailures.Remove(book.Lib, book.Src)
+	default:
+		i.progress.incFailures()
+		failures.Inc(stage)
+		err = i.repoFailures.Add(entities.NewIndexFailure(book, stage, err))
+	}
+
+	if err != nil {
+		i.logger.Error().Str("task", task.ID()).Err(err).Msg("memorize failure")
+	}
+}
+
+// countBook updates report of the current run with the book of the task.
+func (i *Indexer) countBook(task pipeline.Task, count func(*entities.IndexRun, entities.Book)) {
+	item, ok := task.(tasks.FailedItem)
+	if !ok {
+		return
+	}
+
+	i.reportMu.Lock()
+	defer i.reportMu.Unlock()
+
+	if i.report != nil {
+		count(i.report, item.FailedItem())
+	}
+}
+
+// countSkipped counts books rejected by the index rules or by the newer revision, other books are counted as failed.
+func (i *Indexer) countSkipped(task pipeline.Task, err error) {
+	var mismatch *entities.ErrRuleMismatch
+
+	switch {
+	case errors.As(err, &mismatch):
+		i.countBook(task, func(report *entities.IndexRun, book entities.Book) {
+			report.Skipped(book, string(mismatch.Field))
+		})
+	case errors.Is(err, repos.ErrOlderDocRevision):
+		i.countBook(task, (*entities.IndexRun).Duplicated)
+	default:
+		i.countBook(task, (*entities.IndexRun).Failed)
+	}
+}
+
+// entryProcessed moves checkpoint of the archive, which entry is processed.
+func (i *Indexer) entryProcessed(task pipeline.Task) {
+	item, ok := task.(tasks.FailedItem)
+	if !ok {
+		return
+	}
+
+	book := item.FailedItem()
+
+	if cp, ok := i.entries.LoadAndDelete(entryKey(book)); ok {
+		cp.(*checkpoints).processed(book.Offset)
+	}
+}
+
+func entryKey(book entities.Book) string {
+	return fmt.Sprintf("%s:%s:%d", book.Lib, book.Src, book.Offset)
+}
+
+func (i *Indexer) newReaderTaskFactory(lib entities.Library) tasks.PushReadTask {
+	_, rules := i.getLibs()
+
+	return func(reader io.ReadCloser, book entities.Book) error {
+		if err := i.wait(); err != nil {
+			reader.Close()
+			return err
+		}
+
+		i.cntTotal.Inc(1)
+
+		i.reportMu.Lock()
+		if i.report != nil {
+			i.report.Found(book)
+		}
+		i.reportMu.Unlock()
+
+		return i.readingPool.Push(tasks.NewReadTask(book, reader, func(data io.Reader) error {
+			if book.Format() == entities.BookFormatEPUB {
+				return i.parsingPool.Push(tasks.NewParseEPUBTask(
+					data, book, rules, i.repoBooks, i.progress, i.logger, i.detectLang,
+				))
+			}
+
+			return i.parsingPool.Push(tasks.NewParseFB2Task(
+				data, book, rules, lib.Encoder, i.repoBooks, i.progress, i.logger, i.detectLang,
+			))
+		}))
+	}
+}
+
+// PushItem pushes library item (book, archive or compressed book file) to indexing.
+func (i *Indexer) PushItem(num, total int, itemPath string, lib entities.Library) error {
+	if err := i.wait(); err != nil {
+		return err
+	}
+
+	readerTaskFactory := i.newReaderTaskFactory(lib)
+
+	return i.pipe.Push(tasks.NewDefineItemTask(itemPath, lib, i.repoMarks, i.progress, readerTaskFactory,
+		func(finfo fs.FileInfo) error {
+			return i.readArchive(num, total, itemPath, finfo, lib, readerTaskFactory)
+		},
+	))
+}
+
+// readArchive pushes archive entries after its checkpoint. Checkpoints are saved together with books batches, so
+// interrupted archive is resumed from the last saved entry and it is marked after books of all entries are saved.
+func (i *Indexer) readArchive(num, total int, itemPath string, finfo fs.FileInfo, lib entities.Library,
+	readerTaskFactory tasks.PushReadTask,
+) error {
+	cp := newCheckpoints(func(offset uint64, complete bool) {
+		if err := i.repoBooks.AfterSave(func() {
+			var err error
+			if complete {
+				err = i.repoMarks.AddMark(itemPath)
+			} else {
+				err = i.repoMarks.SetCheckpoint(itemPath, offset)
+			}
+
+			if err != nil {
+				i.logger.Error().Err(err).Str("item", itemPath).Uint64("offset", offset).Msg("save checkpoint")
+			}
+		}); err != nil {
+			i.logger.Error().Err(err).Str("item", itemPath).Uint64("offset", offset).Msg("save checkpoint")
+		}
+	})
+
+	checkpoint := i.repoMarks.GetCheckpoint(itemPath)
+	if checkpoint > 0 {
+		i.logger.Info().Str("item", itemPath).Uint64("offset", checkpoint).Msg("resume archive")
+	}
+
+	err := tasks.NewReadArchiveTask(num, total, itemPath, finfo, lib, i.bars, i.progress, checkpoint,
+		func(reader io.ReadCloser, book entities.Book) error {
+			key := entryKey(book)
+			cp.add(book.Offset)
+			i.entries.Store(key, cp)
+
+			if err := readerTaskFactory(reader, book); err != nil {
+				i.entries.Delete(key)
+				return err
+			}
+
+			return nil
+		},
+	).Do()
+
+	cp.finish(err == nil)
+
+	return err
+}
+
+// SetLibs replaces libraries and index rules, e.g. after config reload. Running indexing keeps its libraries,
+// new rules are checked for items pushed after that.
+func (i *Indexer) SetLibs(libs entities.Libraries, rules *entities.IndexRules) {
+	i.libsMu.Lock()
+	defer i.libsMu.Unlock()
+
+	i.libs, i.rules = libs, rules
+}
+
+func (i *Indexer) getLibs() (entities.Libraries, *entities.IndexRules) {
+	i.libsMu.RLock()
+	defer i.libsMu.RUnlock()
+
+	return i.libs, i.rules
+}
+
+// PushFailure pushes item failed at previous runs to indexing again.
+func (i *Indexer) PushFailure(num, total int, failure entities.IndexFailure) error {
+	libs, _ := i.getLibs()
+
+	lib, ok := libs[failure.Lib]
+	if !ok || lib.Disabled {
+		return nil
+	}
+
+	if failure.Stage == entities.FailureStageDefine {
+		return i.PushItem(num, total, path.Join(lib.Dir, failure.Src), lib)
+	}
+
+	book := failure.Book()
+
+	reader, err := libs.OpenBook(&book)
+	if err != nil {
+		i.cntTotal.Inc(1)
+		return err
+	}
+
+	return i.newReaderTaskFactory(lib)(reader, book)
+}
+
+// Run indexes items of the library or items of all libraries if the name is empty. Indexing is canceled with
+// the context, pushed items are processed during the grace timeout after that.
+func (i *Indexer) Run(ctx context.Context, libName string) error {
+	libs, err := i.begin(libName)
+	if err != nil {
+		return err
+	}
+
+	return i.run(ctx, libName, libs)
+}
+
+// Start runs indexing in background, the callback is called after indexing is finished.
+func (i *Indexer) Start(ctx context.Context, libName string, done func(error)) error {
+	libs, err := i.begin(libName)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		done(i.run(ctx, libName, libs))
+	}()
+
+	return nil
+}
+
+func (i *Indexer) begin(libName string) (entities.Libraries, error) {
+	libs, _ := i.getLibs()
+
+	if libName != "" {
+		lib, ok := libs[libName]
+		if !ok || lib.Disabled {
+			return nil, fmt.Errorf("undefined lib name %s", libName)
+		}
+
+		libs = entities.Libraries{libName: lib}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.running {
+		return nil, ErrRunning
+	}
+
+	i.running, i.canceled = true, false
+	i.runs.Add(1)
+
+	return libs, nil
+}
+
+func (i *Indexer) run(ctx context.Context, libName string, libs entities.Libraries) error {
+	return i.process(ctx, entities.IndexRunModeIndex, libName, func() error {
+		items, err := libs.GetItems()
+		if err != nil {
+			return err
+		}
+
+		i.progress.Reset(libName, int64(len(items)), libs.GetSize())
+
+		for k, item := range items {
+			if err = i.PushItem(k+1, len(items), item.Item, libs[item.Lib]); errors.Is(err, ErrCanceled) {
+				break
+			} else if err != nil {
+				i.logger.Error().Str("lib", item.Lib).Str("item", item.Item).Err(err).Msg("push item")
+			}
+		}
+
+		return nil
+	})
+}
+
+// Retry indexes items failed at previous runs.
+func (i *Indexer) Retry(ctx context.Context, failures []entities.IndexFailure) error {
+	if _, err := i.begin(""); err != nil {
+		return err
+	}
+
+	return i.process(ctx, entities.IndexRunModeRetry, "", func() error {
+		i.progress.Reset("", int64(len(failures)), 0)
+
+		for k, failure := range failures {
+			if err := i.PushFailure(k+1, len(failures), failure); errors.Is(err, ErrCanceled) {
+				break
+			} else if err != nil {
+				i.logger.Error().Str("lib", failure.Lib).Str("src", failure.Src).Err(err).Msg("retry")
+			}
+		}
+
+		return nil
+	})
+}
+
+// process pushes items and waits until they are indexed, state of the indexer is reset and report of the run is
+// saved after that.
+func (i *Indexer) process(ctx context.Context, mode, libName string, push func() error) (err error) {
+	stop := make(chan struct{})
+	start := time.Now()
+	running.Set(1)
+
+	i.reportMu.Lock()
+	i.report = entities.NewIndexRun(mode, libName)
+	i.reportMu.Unlock()
+
+	defer func() {
+		close(stop)
+		i.saveReport(err)
+		i.progress.Finish()
+
+		running.Set(0)
+		lastRunTime.Set(time.Since(start).Seconds())
+		lastRunEndTS.Set(float64(time.Now().Unix()))
+
+		i.mu.Lock()
+		defer i.mu.Unlock()
+
+		i.running, i.canceled = false, false
+		i.unpause()
+		i.runs.Done()
+	}()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			i.Cancel()
+		case <-stop:
+		}
+	}()
+
+	if err = push(); err != nil {
+		return err
+	}
+
+	if !i.waitGrace(ctx) {
+		i.mu.Lock()
+		i.abandoned = true
+		i.mu.Unlock()
+
+		return ErrGraceTimeout
+	}
+
+	if i.isCanceled() {
+		return ErrCanceled
+	}
+
+	return nil
+}
+
+func (i *Indexer) saveReport(err error) {
+	status := entities.IndexRunFinished
+
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrCanceled):
+		status = entities.IndexRunCanceled
+	case errors.Is(err, ErrGraceTimeout):
+		status = entities.IndexRunInterrupted
+	default:
+		status = entities.IndexRunFailed
+	}
+
+	i.reportMu.Lock()
+	defer i.reportMu.Unlock()
+
+	i.report.Finish(status, err)
+
+	if err = i.repoRuns.Save(i.report); err != nil {
+		i.logger.Error().Err(err).Str("run", i.report.ID).Msg("save run report")
+	}
+}
+
+// waitGrace waits until pushed items are indexed, only the grace timeout is waited after the context is canceled.
+func (i *Indexer) waitGrace(ctx context.Context) bool {
+	done := make(chan struct{})
+
+	go func() {
+		i.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+	}
+
+	timer := time.NewTimer(i.graceTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Pause stops pushing of items and books to the pools until indexing is resumed.
+func (i *Indexer) Pause() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.running && !i.canceled && i.resume == nil {
+		i.resume = make(chan struct{})
+	}
+}
+
+func (i *Indexer) Resume() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.unpause()
+}
+
+// Cancel stops pushing of items and books to the pools, pushed ones are still processed.
+func (i *Indexer) Cancel() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.running {
+		i.canceled = true
+		i.unpause()
+	}
+}
+
+func (i *Indexer) unpause() {
+	if i.resume != nil {
+		close(i.resume)
+		i.resume = nil
+	}
+}
+
+func (i *Indexer) isCanceled() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.canceled
+}
+
+// wait blocks while indexing is paused, ErrCanceled is returned after indexing is canceled.
+func (i *Indexer) wait() error {
+	i.mu.Lock()
+	resume := i.resume
+	i.mu.Unlock()
+
+	if resume != nil {
+		<-resume
+	}
+
+	if i.isCanceled() {
+		return ErrCanceled
+	}
+
+	return nil
+}
+
+func (i *Indexer) State() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	switch {
+	case !i.running:
+		return StateIdle
+	case i.canceled:
+		return StateCanceling
+	case i.resume != nil:
+		return StatePaused
+	default:
+		return StateRunning
+	}
+}
+
+// Progress returns counters of the current or the last run.
+func (i *Indexer) Progress() ProgressStats {
+	return i.progress.Stats(i.State())
+}
+
+// Wait blocks until pushed items are indexed and their books are saved.
+func (i *Indexer) Wait() {
+	i.wg.Wait()
+	i.repoBooks.Flush()
+}
+
+// Report returns report of the current or the last run, it is nil before the first run.
+func (i *Indexer) Report() *entities.IndexRun {
+	i.reportMu.Lock()
+	defer i.reportMu.Unlock()
+
+	return i.report
+}
+
+// Succeed returns count of indexed books.
+func (i *Indexer) Succeed() uint32 {
+	return i.cntIndexed.Total()
+}
+
+// Failed returns count of books, which were read but not indexed.
+func (i *Indexer) Failed() uint32 {
+	return i.cntTotal.Total() - i.cntIndexed.Total()
+}
+
+// Close cancels indexing and closes pools after the current run is finished. Pools are left opened if the run
+// has exceeded the grace timeout, because they are still used by workers.
+func (i *Indexer) Close() error {
+	i.Cancel()
+	i.runs.Wait()
+
+	i.mu.Lock()
+	abandoned := i.abandoned
+	i.mu.Unlock()
+
+	if abandoned {
+		return ErrGraceTimeout
+	}
+
+	i.pipe.Close()
+	i.readingPool.Close()
+
+	return i.parsingPool.Close()
+}
@@ -0,0 +1,20 @@
+package indexer
+
+import (
+	"github.com/egnd/fb2lib/pkg/metrics"
+)
+
+var (
+	itemsProcessed = metrics.NewCounter("fb2lib_indexer_items_total",
+		"Count of processed library items (books, archives or compressed books files).",
+	)
+	booksIndexed = metrics.NewCounter("fb2lib_indexer_books_total", "Count of indexed books.")
+	bytesRead    = metrics.NewCounter("fb2lib_indexer_read_bytes_total", "Size of read library items.")
+	failures     = metrics.NewCounter("fb2lib_indexer_failures_total", "Count of indexing failures.", "stage")
+	parseTime    = metrics.NewHistogram("fb2lib_indexer_parse_duration_seconds", "Duration of books parsing.",
+		[]float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	)
+	running      = metrics.NewGauge("fb2lib_indexer_running", "Indexing is running.")
+	lastRunTime  = metrics.NewGauge("fb2lib_indexer_last_run_duration_seconds", "Duration of the last indexing.")
+	lastRunEndTS = metrics.NewGauge("fb2lib_indexer_last_run_timestamp_seconds", "Finish time of the last indexing.")
+)
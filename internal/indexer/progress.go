@@ -0,0 +1,123 @@
+package indexer
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vbauerster/mpb/v7"
+)
+
+const (
+	StateIdle      = "idle"
+	StateRunning   = "running"
+	StatePaused    = "paused"
+	StateCanceling = "canceling"
+)
+
+// Progress counts processed items and bytes together with the progress bar.
+type Progress struct {
+	bar        *mpb.Bar
+	mu         sync.Mutex
+	lib        string
+	startTS    time.Time
+	finishTS   time.Time
+	files      int64
+	filesTotal int64
+	bytes      int64
+	bytesTotal int64
+	books      uint32
+	failures   uint32
+}
+
+func NewProgress(bar *mpb.Bar) *Progress {
+	now := time.Now()
+
+	return &Progress{bar: bar, startTS: now, finishTS: now}
+}
+
+// Reset starts counting of the new run.
+func (p *Progress) Reset(lib string, filesTotal, bytesTotal int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lib, p.startTS, p.finishTS = lib, time.Now(), time.Time{}
+	atomic.StoreInt64(&p.files, 0)
+	atomic.StoreInt64(&p.filesTotal, filesTotal)
+	atomic.StoreInt64(&p.bytes, 0)
+	atomic.StoreInt64(&p.bytesTotal, bytesTotal)
+	atomic.StoreUint32(&p.books, 0)
+	atomic.StoreUint32(&p.failures, 0)
+}
+
+// Finish stops counting of the elapsed time.
+func (p *Progress) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.finishTS = time.Now()
+}
+
+func (p *Progress) IncrInt64(n int64) {
+	atomic.AddInt64(&p.bytes, n)
+	bytesRead.Add(float64(n))
+
+	if p.bar != nil {
+		p.bar.IncrInt64(n)
+	}
+}
+
+func (p *Progress) incFiles() {
+	atomic.AddInt64(&p.files, 1)
+}
+
+func (p *Progress) incBooks() {
+	atomic.AddUint32(&p.books, 1)
+}
+
+func (p *Progress) incFailures() {
+	atomic.AddUint32(&p.failures, 1)
+}
+
+// Stats returns current values of the counters, ETA is estimated by processed bytes.
+func (p *Progress) Stats(state string) ProgressStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	res := ProgressStats{
+		State:      state,
+		Lib:        p.lib,
+		Files:      atomic.LoadInt64(&p.files),
+		FilesTotal: atomic.LoadInt64(&p.filesTotal),
+		Bytes:      atomic.LoadInt64(&p.bytes),
+		BytesTotal: atomic.LoadInt64(&p.bytesTotal),
+		Books:      atomic.LoadUint32(&p.books),
+		Failures:   atomic.LoadUint32(&p.failures),
+	}
+
+	if p.finishTS.IsZero() {
+		res.Elapsed = time.Since(p.startTS).Round(time.Second)
+	} else {
+		res.Elapsed = p.finishTS.Sub(p.startTS).Round(time.Second)
+	}
+
+	if res.Bytes > 0 && res.BytesTotal > res.Bytes {
+		res.ETA = time.Duration(float64(res.Elapsed) * float64(res.BytesTotal-res.Bytes) / float64(res.Bytes)).
+			Round(time.Second)
+	}
+
+	return res
+}
+
+type ProgressStats struct {
+	State      string        `json:"state"`
+	Lib        string        `json:"lib"`
+	Files      int64         `json:"files"`
+	FilesTotal int64         `json:"files_total"`
+	Bytes      int64         `json:"bytes"`
+	BytesTotal int64         `json:"bytes_total"`
+	Books      uint32        `json:"books"`
+	Failures   uint32        `json:"failures"`
+	Elapsed    time.Duration `json:"elapsed"`
+	ETA        time.Duration `json:"eta"`
+}
@@ -0,0 +1,165 @@
+package indexer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/egnd/go-pipeline/pools"
+	"github.com/egnd/go-pipeline/tasks"
+	"github.com/rs/zerolog"
+	"github.com/vbauerster/mpb/v7"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/repos"
+)
+
+// BuildSummary calculates genres, tags, series, langs, libs and authors frequencies of indexed books.
+// Summary buckets should be empty before building, the summary is incomplete if the context is canceled.
+func BuildSummary(ctx context.Context, repo *repos.BooksLevelBleve, batchSize int, bar *mpb.Bar,
+	logger zerolog.Logger,
+) (cnt uint64) {
+	resetStats := func() map[repos.BucketType]entities.ItemFreqMap {
+		return newSummaryStats(batchSize)
+	}
+
+	var step int
+
+	stats := resetStats()
+	authors := entities.AuthorsRegistry{}
+
+	pipe := pools.NewSemaphore(len(stats), nil)
+	defer pipe.Close()
+
+	saveStats := func() {
+		for bucket, freq := range stats {
+			bucket, freq := bucket, freq
+			pipe.Push(tasks.NewFunc(string(bucket), func() error {
+				if err := repo.AppendFreqs(bucket, freq); err != nil {
+					logger.Warn().Err(err).Str("bucket", string(bucket)).Int("len", len(freq)).Msg("batch")
+				} else {
+					logger.Debug().Str("bucket", string(bucket)).Int("len", len(freq)).Msg("batch")
+				}
+
+				return nil
+			}))
+		}
+		pipe.Wait()
+	}
+
+	if err := repo.IterateOver(ctx, func(book *entities.Book) error {
+		defer func() {
+			if bar != nil {
+				bar.Increment()
+			}
+		}()
+
+		if book.DupOf != "" {
+			return nil
+		}
+
+		logger.Debug().Str("book", book.ID).Msg("calculate")
+		putSummary(stats, authors, book, repo.ResolveAuthorKey)
+
+		step++
+		cnt++
+		if step < batchSize {
+			return nil
+		}
+
+		saveStats()
+		stats = resetStats()
+		step = 0
+		return nil
+	}); err != nil && ctx.Err() == nil {
+		logger.Error().Err(err).Msg("iterating over books")
+	}
+
+	saveStats()
+
+	authors.Compact()
+	if err := repo.SaveAuthors(authors); err != nil {
+		logger.Error().Err(err).Int("len", len(authors)).Msg("save authors")
+	}
+
+	return
+}
+
+var refreshMu sync.Mutex
+
+// RefreshSummary saves pending books and rebuilds summary of all books. The summary is built in memory and replaces
+// the previous one at once, so pages are not empty while it is built. Refreshes are serialized.
+func RefreshSummary(ctx context.Context, repo *repos.BooksLevelBleve, batchSize int, logger zerolog.Logger) {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+
+	repo.Flush()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	var cnt uint64
+
+	stats := newSummaryStats(batchSize)
+	authors := entities.AuthorsRegistry{}
+
+	if err := repo.IterateOver(ctx, func(book *entities.Book) error {
+		if book.DupOf == "" {
+			putSummary(stats, authors, book, repo.ResolveAuthorKey)
+			cnt++
+		}
+
+		return nil
+	}); err != nil || ctx.Err() != nil {
+		logger.Warn().Err(err).Uint64("cnt", cnt).Msg("summary is not refreshed")
+		return
+	}
+
+	for bucket, freq := range stats {
+		if err := repo.ReplaceFreqs(bucket, freq); err != nil {
+			logger.Error().Err(err).Str("bucket", string(bucket)).Msg("replace summary")
+			return
+		}
+	}
+
+	authors.Compact()
+	if err := repo.ReplaceAuthors(authors); err != nil {
+		logger.Error().Err(err).Int("len", len(authors)).Msg("replace authors")
+		return
+	}
+
+	logger.Info().Uint64("cnt", cnt).Msg("summary built")
+}
+
+func newSummaryStats(size int) map[repos.BucketType]entities.ItemFreqMap {
+	return map[repos.BucketType]entities.ItemFreqMap{
+		repos.BucketGenres:    make(entities.ItemFreqMap, size),
+		repos.BucketGenreCats: make(entities.ItemFreqMap, size),
+		repos.BucketTags:      make(entities.ItemFreqMap, size),
+		repos.BucketSeries:    make(entities.ItemFreqMap, size),
+		repos.BucketLangs:     make(entities.ItemFreqMap, size),
+		repos.BucketLibs:      make(entities.ItemFreqMap, size),
+	}
+}
+
+func putSummary(stats map[repos.BucketType]entities.ItemFreqMap, authors entities.AuthorsRegistry,
+	book *entities.Book, resolveAuthor func(string) string,
+) {
+	for _, k := range book.Genres() {
+		stats[repos.BucketGenres].Put(k, 1)
+	}
+	for _, k := range entities.GenresCategories(book.Genres()) {
+		stats[repos.BucketGenreCats].Put(k, 1)
+	}
+	for _, k := range book.Keywords() {
+		stats[repos.BucketTags].Put(k, 1)
+	}
+	for _, ref := range book.AuthorsRefs() {
+		authors.Put(ref, resolveAuthor, 1)
+	}
+	for _, k := range book.Series() {
+		stats[repos.BucketSeries].Put(k, 1)
+	}
+	stats[repos.BucketLangs].Put(book.Info.Lang, 1)
+	stats[repos.BucketLibs].Put(book.Lib, 1)
+}
@@ -0,0 +1,308 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/repos"
+)
+
+// Watcher indexes files, which are added, changed or removed at libraries dirs. Events are collected until
+// libraries are quiet for the delay, so files being copied are indexed once.
+type Watcher struct {
+	delay        time.Duration
+	batchSize    int
+	libs         entities.Libraries
+	libsMu       sync.RWMutex
+	indexer      *Indexer
+	repoBooks    *repos.BooksLevelBleve
+	repoMarks    *repos.LibMarks
+	repoFailures *repos.IndexFailures
+	logger       zerolog.Logger
+	events       *fsnotify.Watcher
+	done         chan struct{}
+}
+
+func NewWatcher(cfg *viper.Viper, libs entities.Libraries, indexer *Indexer,
+	repoBooks *repos.BooksLevelBleve, repoMarks *repos.LibMarks, repoFailures *repos.IndexFailures,
+	logger zerolog.Logger,
+) (*Watcher, error) {
+	events, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	delay := cfg.GetDuration("indexer.watch.delay")
+	if delay == 0 {
+		delay = 10 * time.Second
+	}
+
+	return &Watcher{
+		delay:        delay,
+		batchSize:    cfg.GetInt("indexer.batch_size"),
+		libs:         libs,
+		indexer:      indexer,
+		repoBooks:    repoBooks,
+		repoMarks:    repoMarks,
+		repoFailures: repoFailures,
+		logger:       logger,
+		events:       events,
+		done:         make(chan struct{}),
+	}, nil
+}
+
+// Run watches libraries dirs until the context is canceled.
+func (w *Watcher) Run(ctx context.Context) error {
+	defer close(w.done)
+
+	for _, lib := range w.getLibs() {
+		if lib.Disabled {
+			continue
+		}
+
+		if err := w.watchDir(lib.Dir); err != nil {
+			return err
+		}
+	}
+
+	w.logger.Info().Dur("delay", w.delay).Msg("watching libraries")
+
+	changes := map[string]struct{}{}
+	timer := time.NewTimer(w.delay)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case err, ok := <-w.events.Errors:
+			if !ok {
+				return nil
+			}
+
+			w.logger.Error().Err(err).Msg("watch libraries")
+		case event, ok := <-w.events.Events:
+			if !ok {
+				return nil
+			}
+
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			w.logger.Debug().Str("item", event.Name).Str("op", event.Op.String()).Msg("library changed")
+			changes[event.Name] = struct{}{}
+			timer.Reset(w.delay)
+		case <-timer.C:
+			w.update(ctx, changes)
+			changes = map[string]struct{}{}
+		}
+	}
+}
+
+// update removes books of changed items and indexes them again, summary is rebuilt after that.
+func (w *Watcher) update(ctx context.Context, changes map[string]struct{}) {
+	var (
+		pushed  int
+		items   []string
+		removed = map[string][]string{}
+		libs    = map[string]entities.Library{}
+	)
+
+	for item := range changes {
+		lib, ok := w.itemLib(item)
+		if !ok {
+			continue
+		}
+
+		src := strings.TrimPrefix(item, lib.Dir)
+		removed[lib.Name] = append(removed[lib.Name], src)
+
+		if err := w.repoMarks.RemoveMarks(item); err != nil {
+			w.logger.Error().Err(err).Str("item", item).Msg("watch: remove marks")
+		}
+
+		if err := w.repoFailures.Remove(lib.Name, src); err != nil {
+			w.logger.Error().Err(err).Str("item", item).Msg("watch: remove failure")
+		}
+
+		finfo, err := os.Stat(item)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue
+		case err != nil:
+			w.logger.Error().Err(err).Str("item", item).Msg("watch: stat")
+			continue
+		case finfo.IsDir():
+			if err = w.watchDir(item); err != nil {
+				w.logger.Error().Err(err).Str("item", item).Msg("watch: add dir")
+			}
+
+			dirItems, err := lib.GetDirItems(item)
+			if err != nil {
+				w.logger.Error().Err(err).Str("item", item).Msg("watch: read dir")
+			}
+
+			for _, dirItem := range dirItems {
+				if _, ok := libs[dirItem]; !ok {
+					items = append(items, dirItem)
+				}
+
+				libs[dirItem] = lib
+			}
+		case lib.IsItem(item):
+			if _, ok := libs[item]; !ok {
+				items = append(items, item)
+			}
+
+			libs[item] = lib
+		}
+	}
+
+	for libName, srcs := range removed {
+		cnt, err := w.repoBooks.RemoveLibItems(ctx, libName, srcs)
+		if err != nil {
+			w.logger.Error().Err(err).Str("lib", libName).Msg("watch: remove books")
+		}
+
+		w.logger.Info().Str("lib", libName).Int("cnt", cnt).Msg("watch: books removed")
+	}
+
+	for k, item := range items {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if err := w.indexer.PushItem(k+1, len(items), item, libs[item]); err != nil {
+			w.logger.Error().Err(err).Str("item", item).Msg("watch: push item")
+			continue
+		}
+
+		pushed++
+	}
+
+	w.indexer.Wait()
+	w.logger.Info().Int("items", pushed).Msg("watch: items indexed")
+
+	w.Refresh(ctx)
+}
+
+// Refresh saves pending books and rebuilds books summary.
+func (w *Watcher) Refresh(ctx context.Context) {
+	RefreshSummary(ctx, w.repoBooks, w.batchSize, w.logger)
+}
+
+// SetLibs replaces watched libraries, e.g. after config reload: dirs of new or enabled libraries are watched and
+// dirs of removed or disabled ones are not watched anymore.
+func (w *Watcher) SetLibs(libs entities.Libraries) (err error) {
+	w.libsMu.Lock()
+	defer w.libsMu.Unlock()
+
+	prevDirs, dirs := enabledDirs(w.libs), enabledDirs(libs)
+	w.libs = libs
+
+	for dir := range prevDirs {
+		if _, ok := dirs[dir]; !ok {
+			w.unwatchDir(dir)
+		}
+	}
+
+	for dir := range dirs {
+		if _, ok := prevDirs[dir]; ok {
+			continue
+		}
+
+		if dirErr := w.watchDir(dir); dirErr != nil && err == nil {
+			err = dirErr
+		}
+	}
+
+	return
+}
+
+func (w *Watcher) getLibs() entities.Libraries {
+	w.libsMu.RLock()
+	defer w.libsMu.RUnlock()
+
+	return w.libs
+}
+
+func enabledDirs(libs entities.Libraries) map[string]struct{} {
+	res := make(map[string]struct{}, len(libs))
+
+	for _, lib := range libs {
+		if !lib.Disabled {
+			res[lib.Dir] = struct{}{}
+		}
+	}
+
+	return res
+}
+
+// itemLib returns library of the item, the library with the longest dir is chosen for nested dirs.
+func (w *Watcher) itemLib(item string) (res entities.Library, ok bool) {
+	for _, lib := range w.getLibs() {
+		if lib.Disabled || (item != lib.Dir && !strings.HasPrefix(item, strings.TrimSuffix(lib.Dir, "/")+"/")) {
+			continue
+		}
+
+		if !ok || len(lib.Dir) > len(res.Dir) {
+			res, ok = lib, true
+		}
+	}
+
+	return
+}
+
+func (w *Watcher) watchDir(dir string) error {
+	return filepath.WalkDir(dir, func(item string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() {
+			return nil
+		}
+
+		return w.events.Add(item)
+	})
+}
+
+// unwatchDir stops watching the dir and its subdirs, which don't belong to other libraries, libsMu should be locked
+// by the caller. Watches of removed dirs are dropped by fsnotify itself.
+func (w *Watcher) unwatchDir(dir string) {
+	_ = filepath.WalkDir(dir, func(item string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+
+		for _, lib := range w.libs {
+			if !lib.Disabled && (item == lib.Dir || strings.HasPrefix(item, strings.TrimSuffix(lib.Dir, "/")+"/")) {
+				return filepath.SkipDir
+			}
+		}
+
+		_ = w.events.Remove(item)
+
+		return nil
+	})
+}
+
+// Close waits for the current update after the watching context is canceled.
+func (w *Watcher) Close() error {
+	<-w.done
+
+	return w.events.Close()
+}
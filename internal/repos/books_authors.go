@@ -0,0 +1,317 @@
+package repos
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+const (
+	authorsRegIDPrefix  = "id:"
+	authorsRegKeyPrefix = "key:"
+	authorsReindexPage  = 500
+)
+
+func (r *BooksLevelBleve) SetAuthorsAliases(aliases entities.AuthorAliases) *BooksLevelBleve {
+	r.aliases = aliases
+
+	return r
+}
+
+// ResolveAuthorKey returns canonical author id for the key, manual aliases have priority over config ones.
+func (r *BooksLevelBleve) ResolveAuthorKey(key string) string {
+	if bucket, ok := r.buckets[BucketAliases]; ok {
+		if data, err := bucket.Get([]byte(key), nil); err == nil {
+			return string(data)
+		}
+	}
+
+	return r.aliases.Resolve(key)
+}
+
+func (r *BooksLevelBleve) GetAuthor(authorID string) (*entities.Author, error) {
+	bucket, ok := r.buckets[BucketAuthReg]
+	if !ok {
+		return nil, nil
+	}
+
+	data, err := bucket.Get([]byte(authorsRegIDPrefix+authorID), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var res entities.Author
+	if err = r.decode(data, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (r *BooksLevelBleve) GetAuthorByKey(key string) (*entities.Author, error) {
+	bucket, ok := r.buckets[BucketAuthReg]
+	if !ok || key == "" {
+		return nil, nil
+	}
+
+	authorID := r.ResolveAuthorKey(key)
+
+	if authorID == key {
+		if data, err := bucket.Get([]byte(authorsRegKeyPrefix+key), nil); err == nil {
+			authorID = string(data)
+		}
+	}
+
+	return r.GetAuthor(authorID)
+}
+
+func (r *BooksLevelBleve) GetAuthorByName(name string) (*entities.Author, error) {
+	return r.GetAuthorByKey(entities.NewAuthorRefFromName(name).Key)
+}
+
+func (r *BooksLevelBleve) GetAuthorsByRefs(refs []entities.AuthorRef) ([]entities.Author, error) {
+	res := make([]entities.Author, 0, len(refs))
+	index := make(map[string]struct{}, len(refs))
+
+	for _, ref := range refs {
+		author, err := r.GetAuthorByKey(ref.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		if author == nil {
+			author = &entities.Author{ID: ref.Key, Val: ref.Name}
+		}
+
+		if _, ok := index[author.ID]; !ok {
+			index[author.ID] = struct{}{}
+			res = append(res, *author)
+		}
+	}
+
+	return res, nil
+}
+
+func (r *BooksLevelBleve) saveAuthor(batch *leveldb.Batch, author *entities.Author) error {
+	data, err := r.encode(author)
+	if err != nil {
+		return err
+	}
+
+	batch.Put([]byte(authorsRegIDPrefix+author.ID), data)
+
+	for _, key := range author.Keys() {
+		batch.Put([]byte(authorsRegKeyPrefix+key), []byte(author.ID))
+	}
+
+	if data, err = r.encode(entities.ItemFreq{Val: author.Val, Freq: author.Freq}); err != nil {
+		return err
+	}
+
+	if listKey := r.authorListKey(author); listKey != "" {
+		if err = r.buckets[BucketAuthors].Put([]byte(listKey), data, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *BooksLevelBleve) authorListKey(author *entities.Author) string {
+	items := entities.ItemFreqMap{}
+	items.Put(author.Val, author.Freq)
+
+	for key := range items {
+		return key
+	}
+
+	return ""
+}
+
+func (r *BooksLevelBleve) SaveAuthors(authors entities.AuthorsRegistry) error {
+	batch := new(leveldb.Batch)
+
+	for _, author := range authors {
+		if err := r.saveAuthor(batch, author); err != nil {
+			return fmt.Errorf("save author %s error: %w", author.ID, err)
+		}
+	}
+
+	return r.buckets[BucketAuthReg].Write(batch, nil)
+}
+
+// ReplaceAuthors replaces authors registry and list with the authors, readers see either previous or new authors.
+func (r *BooksLevelBleve) ReplaceAuthors(authors entities.AuthorsRegistry) error {
+	reg := make(map[string][]byte, len(authors)*2)
+	list := make(map[string][]byte, len(authors))
+
+	for _, author := range authors {
+		data, err := r.encode(author)
+		if err != nil {
+			return fmt.Errorf("save author %s error: %w", author.ID, err)
+		}
+
+		reg[authorsRegIDPrefix+author.ID] = data
+
+		for _, key := range author.Keys() {
+			reg[authorsRegKeyPrefix+key] = []byte(author.ID)
+		}
+
+		listKey := r.authorListKey(author)
+		if listKey == "" {
+			continue
+		}
+
+		if list[listKey], err = r.encode(entities.ItemFreq{Val: author.Val, Freq: author.Freq}); err != nil {
+			return fmt.Errorf("save author %s error: %w", author.ID, err)
+		}
+	}
+
+	if err := r.replaceBucket(BucketAuthReg, reg); err != nil {
+		return err
+	}
+
+	return r.replaceBucket(BucketAuthors, list)
+}
+
+func (r *BooksLevelBleve) MergeAuthors(fromID, toID string) (*entities.Author, error) {
+	if fromID == toID {
+		return nil, errors.New("merge author error: same author")
+	}
+
+	from, err := r.GetAuthor(fromID)
+	if err != nil || from == nil {
+		return nil, fmt.Errorf("merge author error: undefined author %s", fromID)
+	}
+
+	to, err := r.GetAuthor(toID)
+	if err != nil || to == nil {
+		return nil, fmt.Errorf("merge author error: undefined author %s", toID)
+	}
+
+	to.Merge(from)
+
+	aliases := new(leveldb.Batch)
+	for _, key := range from.Keys() {
+		aliases.Put([]byte(key), []byte(to.ID))
+	}
+
+	if err = r.buckets[BucketAliases].Write(aliases, nil); err != nil {
+		return nil, err
+	}
+
+	if listKey := r.authorListKey(from); listKey != "" && listKey != r.authorListKey(to) {
+		if err = r.buckets[BucketAuthors].Delete([]byte(listKey), nil); err != nil {
+			return nil, err
+		}
+	}
+
+	batch := new(leveldb.Batch)
+	batch.Delete([]byte(authorsRegIDPrefix + from.ID))
+
+	if err = r.saveAuthor(batch, to); err != nil {
+		return nil, err
+	}
+
+	if err = r.buckets[BucketAuthReg].Write(batch, nil); err != nil {
+		return nil, err
+	}
+
+	return to, r.reindexAuthorsBooks(from.Keys())
+}
+
+func (r *BooksLevelBleve) SplitAuthor(authorID, key string) (*entities.Author, error) {
+	author, err := r.GetAuthor(authorID)
+	if err != nil || author == nil {
+		return nil, fmt.Errorf("split author error: undefined author %s", authorID)
+	}
+
+	if key == author.ID || !entities.SliceHasString(author.VariantsKeys(), key) {
+		return nil, fmt.Errorf("split author error: invalid key %s", key)
+	}
+
+	oldListKey := r.authorListKey(author)
+	res := author.Split(key)
+
+	if err = r.buckets[BucketAliases].Put([]byte(key), []byte(key), nil); err != nil {
+		return nil, err
+	}
+
+	if listKey := r.authorListKey(author); listKey != oldListKey {
+		if err = r.buckets[BucketAuthors].Delete([]byte(oldListKey), nil); err != nil {
+			return nil, err
+		}
+	}
+
+	batch := new(leveldb.Batch)
+
+	if err = r.saveAuthor(batch, author); err != nil {
+		return nil, err
+	}
+
+	if err = r.saveAuthor(batch, res); err != nil {
+		return nil, err
+	}
+
+	if err = r.buckets[BucketAuthReg].Write(batch, nil); err != nil {
+		return nil, err
+	}
+
+	return res, r.reindexAuthorsBooks([]string{key})
+}
+
+// reindexAuthorsBooks indexes books of the authors keys again, so works of the books are identified by changed
+// aliases.
+func (r *BooksLevelBleve) reindexAuthorsBooks(keys []string) error {
+	items := make([]query.Query, 0, len(keys))
+	for _, key := range keys {
+		termQ := bleve.NewTermQuery(key)
+		termQ.SetField(string(entities.IdxFAuthorKey))
+		items = append(items, termQ)
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	req := bleve.NewSearchRequestOptions(bleve.NewDisjunctionQuery(items...), authorsReindexPage, 0, false)
+	req.SortBy([]string{"_id"})
+
+	for {
+		searchResults, err := r.index.Search(req)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]string, 0, len(searchResults.Hits))
+		for _, item := range searchResults.Hits {
+			ids = append(ids, item.ID)
+		}
+
+		books, err := r.getBooks(ids)
+		if err != nil {
+			return err
+		}
+
+		changed := make([]*entities.Book, 0, len(books))
+		for k := range books {
+			changed = append(changed, &books[k])
+		}
+
+		if err = r.UpdateBooks(changed); err != nil {
+			return err
+		}
+
+		if req.From += len(searchResults.Hits); len(searchResults.Hits) == 0 || req.From >= int(searchResults.Total) {
+			return nil
+		}
+	}
+}
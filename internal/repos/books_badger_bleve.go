@@ -2,11 +2,13 @@ package repos
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/blevesearch/bleve/v2"
 	"github.com/blevesearch/bleve/v2/search"
@@ -28,8 +30,22 @@ const (
 	BucketGenres  BucketType = "genres"
 	BucketLibs    BucketType = "libs"
 	BucketLangs   BucketType = "langs"
+	BucketAuthReg BucketType = "authors_reg"
+	BucketAliases BucketType = "aliases"
+	BucketDocs    BucketType = "docs"
+	BucketTags    BucketType = "tags"
+	// BucketGenreCats has counts of books by genres categories, book with several genres of the category is
+	// counted once.
+	BucketGenreCats BucketType = "genre_cats"
 )
 
+// SummaryBuckets are built from books by the summary.
+var SummaryBuckets = []BucketType{
+	BucketAuthors, BucketAuthReg, BucketSeries, BucketGenres, BucketGenreCats, BucketTags, BucketLibs, BucketLangs,
+}
+
+const annotationBoost = 0.3
+
 type BooksLevelBleve struct {
 	batching bool
 	buckets  map[BucketType]*leveldb.DB
@@ -37,10 +53,15 @@ type BooksLevelBleve struct {
 	encode   entities.IMarshal
 	decode   entities.IUnmarshal
 	logger   zerolog.Logger
+	aliases  entities.AuthorAliases
 	// cache    *cache.Cache @TODO:
-	wg        sync.WaitGroup
-	batchPipe chan *entities.Book
-	batchStop chan struct{}
+	wg         sync.WaitGroup
+	batchPipe  chan *entities.Book
+	batchStop  chan struct{}
+	batchFlush chan chan struct{}
+	batchHooks chan func()
+	docsMu     sync.Mutex
+	docsLinks  map[string]*docLink
 }
 
 func NewBooksLevelBleve(batchSize int,
@@ -57,12 +78,16 @@ func NewBooksLevelBleve(batchSize int,
 		encode:   encode,
 		decode:   decode,
 		logger:   logger,
+
+		docsLinks: map[string]*docLink{},
 	}
 
 	if repo.batching {
 		repo.wg.Add(1)
 		repo.batchStop = make(chan struct{})
 		repo.batchPipe = make(chan *entities.Book)
+		repo.batchFlush = make(chan chan struct{})
+		repo.batchHooks = make(chan func())
 		go repo.runBatching(batchSize)
 	}
 
@@ -72,9 +97,9 @@ func NewBooksLevelBleve(batchSize int,
 func (r *BooksLevelBleve) getBooks(booksIDs []string) ([]entities.Book, error) {
 	res := make([]entities.Book, 0, len(booksIDs))
 
-	var book entities.Book
-
 	for _, itemID := range booksIDs {
+		var book entities.Book
+
 		data, err := r.buckets[BucketBooks].Get([]byte(itemID), nil)
 		if err != nil {
 			return nil, err
@@ -90,6 +115,30 @@ func (r *BooksLevelBleve) getBooks(booksIDs []string) ([]entities.Book, error) {
 	return res, nil
 }
 
+func (r *BooksLevelBleve) GetByIDs(booksIDs []string) ([]entities.Book, error) {
+	return r.getBooks(booksIDs)
+}
+
+// GetExistingByIDs returns books like GetByIDs, but skips removed books.
+func (r *BooksLevelBleve) GetExistingByIDs(booksIDs []string) ([]entities.Book, error) {
+	res := make([]entities.Book, 0, len(booksIDs))
+
+	for _, itemID := range booksIDs {
+		book, err := r.getBooks([]string{itemID})
+		if errors.Is(err, leveldb.ErrNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, book...)
+	}
+
+	return res, nil
+}
+
 func (r *BooksLevelBleve) GetByID(bookID string) (*entities.Book, error) {
 	if bookID == "" {
 		return nil, errors.New("empty book id")
@@ -108,10 +157,19 @@ func (r *BooksLevelBleve) FindBooks(queryStr string,
 ) ([]entities.Book, error) {
 	queryStr = strings.TrimSpace(strings.ToLower(queryStr))
 
+	switch idxField {
+	case entities.IdxFISBN:
+		idxFieldVal = r.isbnTerm(idxFieldVal)
+	case entities.IdxFKeywords:
+		idxFieldVal = entities.NormalizeKeyword(idxFieldVal)
+	}
+
 	var searchQ query.Query
 	var sortField *search.SortField
+	var kind string
 	switch {
 	case idxField != entities.IdxFUndefined && idxFieldVal != "":
+		kind = string(idxField)
 		searchQ = bleve.NewQueryStringQuery(
 			fmt.Sprintf(`+%s:"%s" %s`, idxField, idxFieldVal, queryStr),
 		)
@@ -121,6 +179,7 @@ func (r *BooksLevelBleve) FindBooks(queryStr string,
 			Missing: search.SortFieldMissingLast,
 		}
 	case queryStr == "" || queryStr == "*":
+		kind = "all"
 		searchQ = bleve.NewMatchAllQuery()
 		sortField = &search.SortField{Desc: true,
 			Field:   string(entities.IdxFYear),
@@ -128,11 +187,25 @@ func (r *BooksLevelBleve) FindBooks(queryStr string,
 			Missing: search.SortFieldMissingLast,
 		}
 	default:
-		searchQ = bleve.NewDisjunctionQuery(
+		kind = "query"
+		disjQ := bleve.NewDisjunctionQuery(
 			bleve.NewMatchPhraseQuery(queryStr), // phrase match
 			// bleve.NewWildcardQuery(queryStr),    // wildcards syntax
 			bleve.NewQueryStringQuery(queryStr), // extended search syntax https://blevesearch.com/docs/Query-String-Query/
 		)
+
+		annotQ := bleve.NewMatchQuery(queryStr) // annotations are less relevant than other fields
+		annotQ.SetField(string(entities.IdxFAnnotation))
+		annotQ.SetBoost(annotationBoost)
+		disjQ.AddQuery(annotQ)
+
+		if isbn, err := entities.ParseISBN(queryStr); err == nil {
+			isbnQ := bleve.NewTermQuery(isbn.ISBN13)
+			isbnQ.SetField(string(entities.IdxFISBN))
+			disjQ.AddQuery(isbnQ)
+		}
+
+		searchQ = disjQ
 		sortField = &search.SortField{
 			Field:   string(entities.IdxFTitle),
 			Type:    search.SortFieldAsString,
@@ -140,15 +213,20 @@ func (r *BooksLevelBleve) FindBooks(queryStr string,
 		}
 	}
 
-	req := bleve.NewSearchRequestOptions(searchQ, pager.GetPageSize(), pager.GetOffset(), false)
+	req := bleve.NewSearchRequestOptions(r.excludeDups(searchQ), pager.GetPageSize(), pager.GetOffset(), false)
 	req.Sort = append(req.Sort, sortField)
 	req.Highlight = bleve.NewHighlightWithStyle("html")
 
+	start := time.Now()
 	searchResults, err := r.index.Search(req)
+	searchDuration.ObserveSince(start, kind)
+
 	if err != nil {
+		searchFailures.Inc(kind)
 		return nil, err
 	}
 
+	searchHits.Observe(float64(searchResults.Total), kind)
 	pager.SetTotal(searchResults.Total)
 
 	ids := make([]string, 0, len(searchResults.Hits))
@@ -174,7 +252,18 @@ func (r *BooksLevelBleve) FindBooks(queryStr string,
 	return res, err
 }
 
+// Remove removes the book, its duplicates are shown again and it is removed from alternates of other books.
 func (r *BooksLevelBleve) Remove(bookID string) error { //@TODO: remove book file too
+	if _, err := r.GetByID(bookID); err != nil {
+		return err
+	}
+
+	_, err := r.RemoveBooks(context.Background(), map[string]struct{}{bookID: {}})
+
+	return err
+}
+
+func (r *BooksLevelBleve) remove(bookID string) error {
 	if err := r.index.Delete(bookID); err != nil {
 		return err
 	}
@@ -182,6 +271,101 @@ func (r *BooksLevelBleve) Remove(bookID string) error { //@TODO: remove book fil
 	return r.buckets[BucketBooks].Delete([]byte(bookID), nil)
 }
 
+// unlinkRemoved clears references of the book to removed books and reports whether the book is changed.
+func (r *BooksLevelBleve) unlinkRemoved(book *entities.Book, removed map[string]struct{}) (changed bool) {
+	if _, ok := removed[book.DupOf]; ok && book.DupOf != "" {
+		book.DupOf, changed = "", true
+	}
+
+	alts := book.Alternates[:0]
+	for _, altID := range book.Alternates {
+		if _, ok := removed[altID]; ok {
+			changed = true
+			continue
+		}
+
+		alts = append(alts, altID)
+	}
+
+	if book.Alternates = alts; len(alts) == 0 {
+		book.Alternates = nil
+	}
+
+	return
+}
+
+// RemoveLibItems removes books of the library, which are stored at the items or inside of them (e.g. books of
+// archives or directories). Books marked as duplicates of removed ones are shown again.
+func (r *BooksLevelBleve) RemoveLibItems(ctx context.Context, lib string, items []string) (cnt int, err error) {
+	removed := map[string]struct{}{}
+
+	if err = r.IterateOver(ctx, func(book *entities.Book) error {
+		if book.Lib != lib {
+			return nil
+		}
+
+		for _, item := range items {
+			if book.Src == item || strings.HasPrefix(book.Src, item+"/") {
+				removed[book.ID] = struct{}{}
+				break
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return
+	}
+
+	return r.RemoveBooks(ctx, removed)
+}
+
+// RemoveBooks removes books by their ids and their documents revisions, books marked as duplicates of removed ones
+// are shown again and removed books are removed from alternates of other books.
+func (r *BooksLevelBleve) RemoveBooks(ctx context.Context, removed map[string]struct{}) (cnt int, err error) {
+	if len(removed) == 0 {
+		return
+	}
+
+	for bookID := range removed {
+		if err = r.remove(bookID); err != nil {
+			return
+		}
+
+		cnt++
+	}
+
+	if err = r.removeDocRevisions(removed); err != nil {
+		return
+	}
+
+	var changed []*entities.Book
+
+	if err = r.IterateOver(ctx, func(book *entities.Book) error {
+		if r.unlinkRemoved(book, removed) {
+			changed = append(changed, book)
+		}
+
+		return nil
+	}); err != nil {
+		return
+	}
+
+	err = r.UpdateBooks(changed)
+
+	return
+}
+
+func (r *BooksLevelBleve) excludeDups(searchQ query.Query) query.Query {
+	dupQ := bleve.NewBoolFieldQuery(true)
+	dupQ.SetField(string(entities.IdxFDup))
+
+	res := bleve.NewBooleanQuery()
+	res.AddMust(searchQ)
+	res.AddMustNot(dupQ)
+
+	return res
+}
+
 func (r *BooksLevelBleve) clearSeqs(vals []string) []string {
 	res := make([]string, 0, len(vals))
 
@@ -214,6 +398,41 @@ func (r *BooksLevelBleve) buildOrCond(field entities.IndexField, vals []string)
 	return bleve.NewDisjunctionQuery(items...)
 }
 
+func (r *BooksLevelBleve) buildAuthorsCond(vals []string) query.Query {
+	items := make([]query.Query, 0, len(vals))
+
+	for _, name := range vals {
+		author, err := r.GetAuthorByName(name)
+		if err != nil {
+			r.logger.Warn().Err(err).Str("author", name).Msg("get author")
+		}
+
+		if author == nil {
+			if cond := r.buildOrCond(entities.IdxFAuthor, []string{name}); cond != nil {
+				items = append(items, cond)
+			}
+
+			continue
+		}
+
+		for _, key := range author.Keys() {
+			termQ := bleve.NewTermQuery(key)
+			termQ.SetField(string(entities.IdxFAuthorKey))
+			items = append(items, termQ)
+		}
+
+		if cond := r.buildOrCond(entities.IdxFAuthor, author.Names()); cond != nil {
+			items = append(items, cond)
+		}
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	return bleve.NewDisjunctionQuery(items...)
+}
+
 func (r *BooksLevelBleve) GetSeriesBooks(limit int, series []string, except *entities.Book) (res []entities.Book, err error) {
 	searchQ := r.buildOrCond(entities.IdxFSerie, series)
 	if searchQ == nil {
@@ -226,9 +445,11 @@ func (r *BooksLevelBleve) GetSeriesBooks(limit int, series []string, except *ent
 		)
 	}
 
-	req := bleve.NewSearchRequestOptions(searchQ, limit, 0, false)
+	req := bleve.NewSearchRequestOptions(r.excludeDups(searchQ), limit, 0, false)
 	req.Sort = append(req.Sort, &search.SortField{
 		Field: string(entities.IdxFSerie), Type: search.SortFieldAsString,
+	}, &search.SortField{
+		Field: string(entities.IdxFSerieNum), Type: search.SortFieldAsNumber, Missing: search.SortFieldMissingLast,
 	})
 	searchResults, err := r.index.Search(req)
 	if err != nil {
@@ -243,8 +464,94 @@ func (r *BooksLevelBleve) GetSeriesBooks(limit int, series []string, except *ent
 	return r.getBooks(ids)
 }
 
+func (r *BooksLevelBleve) GetSerieBooks(limit int, serie string) (res []entities.Book, err error) {
+	books, err := r.GetSeriesBooks(limit, []string{serie}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res = books[:0]
+	for _, book := range books {
+		if book.GetSerie(serie) != nil {
+			res = append(res, book)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].GetSerie(serie).Num < res[j].GetSerie(serie).Num
+	})
+
+	return res, nil
+}
+
+func (r *BooksLevelBleve) GetWorkBooks(limit int, workID string, except *entities.Book) (res []entities.Book, err error) {
+	if workID == "" {
+		return
+	}
+
+	workQ := bleve.NewTermQuery(workID)
+	workQ.SetField(string(entities.IdxFWork))
+
+	req := bleve.NewSearchRequestOptions(r.excludeDups(workQ), limit, 0, false)
+	searchResults, err := r.index.Search(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(searchResults.Hits))
+	for _, item := range searchResults.Hits {
+		if except == nil || item.ID != except.ID {
+			ids = append(ids, item.ID)
+		}
+	}
+
+	return r.getBooks(ids)
+}
+
+// GetISBNDupsMains returns main books of duplicates with the isbn, they are used when only duplicates have the isbn.
+func (r *BooksLevelBleve) GetISBNDupsMains(limit int, isbn string) (res []entities.Book, err error) {
+	isbnQ := bleve.NewTermQuery(r.isbnTerm(isbn))
+	isbnQ.SetField(string(entities.IdxFISBN))
+
+	dupQ := bleve.NewBoolFieldQuery(true)
+	dupQ.SetField(string(entities.IdxFDup))
+
+	req := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(isbnQ, dupQ), limit, 0, false)
+	searchResults, err := r.index.Search(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(searchResults.Hits))
+	for _, item := range searchResults.Hits {
+		ids = append(ids, item.ID)
+	}
+
+	dups, err := r.getBooks(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	mains := make(map[string]struct{}, len(dups))
+	for _, dup := range dups {
+		if _, ok := mains[dup.DupOf]; ok || dup.DupOf == "" {
+			continue
+		}
+
+		mains[dup.DupOf] = struct{}{}
+
+		if main, err := r.GetByID(dup.DupOf); err == nil {
+			res = append(res, *main)
+		} else if !errors.Is(err, leveldb.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
 func (r *BooksLevelBleve) GetAuthorsBooks(limit int, authors []string, except *entities.Book) (res []entities.Book, err error) {
-	searchQ := r.buildOrCond(entities.IdxFAuthor, authors)
+	searchQ := r.buildAuthorsCond(authors)
 	if searchQ == nil {
 		return
 	}
@@ -262,7 +569,7 @@ func (r *BooksLevelBleve) GetAuthorsBooks(limit int, authors []string, except *e
 		searchQ = bleve.NewConjunctionQuery(searchQ, bleve.NewQueryStringQuery(buf.String()))
 	}
 
-	req := bleve.NewSearchRequestOptions(searchQ, limit, 0, false)
+	req := bleve.NewSearchRequestOptions(r.excludeDups(searchQ), limit, 0, false)
 	req.Sort = append(req.Sort, &search.SortField{
 		Field: string(entities.IdxFAuthor), Type: search.SortFieldAsString,
 	})
@@ -342,6 +649,14 @@ func (r *BooksLevelBleve) getFreqs(bucket BucketType, prefix ...string) (entitie
 	return res, iter.Error()
 }
 
+func (r *BooksLevelBleve) isbnTerm(val string) string {
+	if isbn, err := entities.ParseISBN(val); err == nil {
+		return isbn.ISBN13
+	}
+
+	return entities.CleanISBN(val)
+}
+
 func (r *BooksLevelBleve) GetGenres(pager pagination.IPager) (entities.FreqsItems, error) {
 	res, err := r.getFreqs(BucketGenres) // @TODO: cache res slice
 	if err != nil {
@@ -363,6 +678,20 @@ func (r *BooksLevelBleve) GetGenres(pager pagination.IPager) (entities.FreqsItem
 	return res[pager.GetOffset() : pager.GetOffset()+pager.GetPageSize()], nil
 }
 
+// GetGenreCats returns counts of books by genres categories.
+func (r *BooksLevelBleve) GetGenreCats() (entities.FreqsItems, error) {
+	return r.getFreqs(BucketGenreCats)
+}
+
+func (r *BooksLevelBleve) GetTags(limit int) ([]entities.TagsCloudItem, error) {
+	res, err := r.getFreqs(BucketTags) // @TODO: cache res slice
+	if err != nil {
+		return nil, err
+	}
+
+	return entities.NewTagsCloud(res, limit), nil
+}
+
 func (r *BooksLevelBleve) GetLibs() (entities.FreqsItems, error) {
 	res, err := r.getFreqs(BucketLibs) // @TODO: cache res slice
 	if err != nil {
@@ -436,6 +765,12 @@ func (r *BooksLevelBleve) GetAuthorsByPrefix(prefix string, pager pagination.IPa
 }
 
 func (r *BooksLevelBleve) SaveBook(book *entities.Book) (err error) {
+	r.takeDocLink(book.ID).apply(book)
+
+	if !r.batching {
+		return r.UpdateBooks([]*entities.Book{book})
+	}
+
 	defer func() {
 		if r := recover(); err == nil && r != nil {
 			err = fmt.Errorf("%v", r)
@@ -453,9 +788,14 @@ func (r *BooksLevelBleve) Close() error {
 		r.wg.Wait()
 		close(r.batchStop)
 		close(r.batchPipe)
+		close(r.batchFlush)
+		close(r.batchHooks)
 	}
 
-	for _, bucketName := range []BucketType{BucketBooks, BucketAuthors, BucketSeries, BucketGenres, BucketLibs} {
+	for _, bucketName := range []BucketType{
+		BucketBooks, BucketAuthors, BucketSeries, BucketGenres, BucketLibs, BucketLangs, BucketAuthReg, BucketAliases,
+		BucketDocs, BucketTags, BucketGenreCats,
+	} {
 		if bucket, ok := r.buckets[bucketName]; ok && bucket != nil {
 			if err := bucket.Close(); err != nil {
 				r.logger.Error().Err(err).Str("bucket", string(bucketName)).Msg("close bucket")
@@ -473,14 +813,37 @@ func (r *BooksLevelBleve) Close() error {
 func (r *BooksLevelBleve) runBatching(batchSize int) {
 	defer r.wg.Done()
 
+	var hooks []func()
+
 	batch := make([]*entities.Book, 0, batchSize)
 	indexBatch := r.index.NewBatch()
 
+	save := func() {
+		_ = r.saveBatch(batch, indexBatch) // errors are logged, nobody waits for piped books
+		batch = batch[:0]
+		indexBatch.Reset()
+
+		for _, hook := range hooks {
+			hook()
+		}
+		hooks = hooks[:0]
+	}
+
 loop:
 	for {
 		select {
 		case <-r.batchStop:
 			break loop
+		case done := <-r.batchFlush:
+			save()
+			close(done)
+		case hook := <-r.batchHooks:
+			if len(batch) == 0 {
+				hook()
+				continue
+			}
+
+			hooks = append(hooks, hook)
 		case doc := <-r.batchPipe:
 			batch = append(batch, doc)
 
@@ -488,16 +851,15 @@ loop:
 				continue
 			}
 
-			r.saveBatch(batch, indexBatch)
-			batch = batch[:0]
-			indexBatch.Reset()
+			save()
 		}
 	}
 
-	r.saveBatch(batch, indexBatch)
+	save()
 }
 
-func (r *BooksLevelBleve) saveBatch(batch []*entities.Book, indexBatch *bleve.Batch) {
+// saveBatch saves and indexes books, failed books are logged and skipped, the first error is returned.
+func (r *BooksLevelBleve) saveBatch(batch []*entities.Book, indexBatch *bleve.Batch) (res error) {
 	if len(batch) == 0 {
 		return
 	}
@@ -512,16 +874,25 @@ func (r *BooksLevelBleve) saveBatch(batch []*entities.Book, indexBatch *bleve.Ba
 
 		if itemData, err = r.encode(item); err != nil {
 			logger.Error().Err(err).Msg("batch err: encode item")
+			if res == nil {
+				res = fmt.Errorf("encode book %s: %w", item.ID, err)
+			}
 			continue
 		}
 
 		if err = r.buckets[BucketBooks].Put([]byte(item.ID), itemData, nil); err != nil {
 			logger.Error().Err(err).Msg("batch err: save item")
+			if res == nil {
+				res = fmt.Errorf("save book %s: %w", item.ID, err)
+			}
 			continue
 		}
 
-		if err = indexBatch.Index(item.ID, item.Index()); err != nil {
+		if err = indexBatch.Index(item.ID, item.Index(r.ResolveAuthorKey)); err != nil {
 			logger.Error().Err(err).Msg("batch err: index item")
+			if res == nil {
+				res = fmt.Errorf("index book %s: %w", item.ID, err)
+			}
 		}
 	}
 
@@ -533,12 +904,59 @@ func (r *BooksLevelBleve) saveBatch(batch []*entities.Book, indexBatch *bleve.Ba
 		if indexBatch.Size() > 0 {
 			if err := r.index.Batch(indexBatch); err != nil {
 				logger.Error().Err(err).Msg("batch err: index batch")
+				if res == nil {
+					res = fmt.Errorf("index batch: %w", err)
+				}
 			}
 		}
 	}()
 
 	wg.Wait()
 	logger.Debug().Msg("batch saved")
+
+	return
+}
+
+// Flush saves books from the current batch immediately.
+func (r *BooksLevelBleve) Flush() {
+	if !r.batching {
+		return
+	}
+
+	// the repo could be closed already, if pools are left after the shutdown grace timeout
+	defer func() { _ = recover() }()
+
+	done := make(chan struct{})
+	r.batchFlush <- done
+	<-done
+}
+
+// AfterSave calls the hook after books, which were passed to SaveBook before it, are saved.
+func (r *BooksLevelBleve) AfterSave(hook func()) (err error) {
+	if !r.batching {
+		hook()
+		return
+	}
+
+	defer func() {
+		if r := recover(); err == nil && r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	r.batchHooks <- hook
+
+	return
+}
+
+// UpdateBooks saves and reindexes books immediately, without batching pipe.
+func (r *BooksLevelBleve) UpdateBooks(books []*entities.Book) error {
+	return r.saveBatch(books, r.index.NewBatch())
+}
+
+// GetIndexedCnt returns count of indexed books without iterating over the store.
+func (r *BooksLevelBleve) GetIndexedCnt() (uint64, error) {
+	return r.index.DocCount()
 }
 
 func (r *BooksLevelBleve) GetTotal() (total uint64) {
@@ -556,16 +974,21 @@ func (r *BooksLevelBleve) GetTotal() (total uint64) {
 	return
 }
 
-func (r *BooksLevelBleve) IterateOver(handlers ...func(*entities.Book) error) error {
+// IterateOver passes stored books to the handlers until all books are passed or the context is canceled.
+func (r *BooksLevelBleve) IterateOver(ctx context.Context, handlers ...func(*entities.Book) error) error {
 	iter := r.buckets[BucketBooks].NewIterator(nil, nil)
 	defer iter.Release()
 
 	for iter.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		var book entities.Book
 
 		if err := r.decode(iter.Value(), &book); err != nil {
 			r.logger.Warn().Err(err).Str("id", string(iter.Key())).Msg("decode book")
-			return nil
+			continue
 		}
 
 		for _, handler := range handlers {
@@ -578,6 +1001,51 @@ func (r *BooksLevelBleve) IterateOver(handlers ...func(*entities.Book) error) er
 	return iter.Error()
 }
 
+// ReplaceFreqs replaces frequencies of the bucket with the items, readers see either previous or new frequencies.
+func (r *BooksLevelBleve) ReplaceFreqs(bucket BucketType, items entities.ItemFreqMap) error {
+	values := make(map[string][]byte, len(items))
+
+	for k, item := range items {
+		data, err := r.encode(item)
+		if err != nil {
+			return err
+		}
+
+		values[k] = data
+	}
+
+	return r.replaceBucket(bucket, values)
+}
+
+// replaceBucket writes the values and removes other keys of the bucket by a single batch.
+func (r *BooksLevelBleve) replaceBucket(bucketName BucketType, values map[string][]byte) error {
+	bucket, ok := r.buckets[bucketName]
+	if !ok {
+		return nil
+	}
+
+	batch := new(leveldb.Batch)
+	iter := bucket.NewIterator(nil, nil)
+
+	for iter.Next() {
+		if _, ok := values[string(iter.Key())]; !ok {
+			batch.Delete(iter.Key())
+		}
+	}
+
+	iter.Release()
+
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	for k, data := range values {
+		batch.Put([]byte(k), data)
+	}
+
+	return bucket.Write(batch, nil)
+}
+
 func (r *BooksLevelBleve) AppendFreqs(bucket BucketType, items entities.ItemFreqMap) (err error) {
 	var data []byte
 	for k, item := range items {
@@ -0,0 +1,239 @@
+package repos
+
+import (
+	"errors"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+var ErrOlderDocRevision = errors.New("newer document revision is already indexed")
+
+type docRevision struct {
+	BookID  string   `json:"book"`
+	Version string   `json:"ver,omitempty"`
+	Title   string   `json:"title,omitempty"`
+	Authors []string `json:"authors,omitempty"`
+	Alts    []string `json:"alts,omitempty"`
+}
+
+// docLink is a pending change of already registered revision, it is applied after the revision is saved.
+type docLink struct {
+	dupOf string
+	alts  []string
+}
+
+func (l *docLink) apply(book *entities.Book) {
+	if l == nil {
+		return
+	}
+
+	if l.dupOf != "" {
+		book.DupOf, book.Alternates = l.dupOf, nil
+	}
+
+	for _, altID := range l.alts {
+		if altID != book.ID && !entities.SliceHasString(book.Alternates, altID) {
+			book.Alternates = append(book.Alternates, altID)
+		}
+	}
+}
+
+// SaveDocRevision registers fb2 document revision of the book, so only the newest revision of the document is shown.
+// Older revision with the same book id is rejected with ErrOlderDocRevision, other revisions are marked as duplicates.
+// Books with the same document id, but with other title or authors, are not treated as revisions.
+func (r *BooksLevelBleve) SaveDocRevision(book *entities.Book) error {
+	savedID, err := r.registerDocRevision(book)
+	if err != nil || savedID == "" {
+		return err
+	}
+
+	// the registered revision could be still waiting at the batch, so it is linked after the batch is saved
+	return r.AfterSave(func() { r.applyDocLink(savedID) })
+}
+
+// registerDocRevision updates the document registry and returns id of the registered revision, which should be linked.
+func (r *BooksLevelBleve) registerDocRevision(book *entities.Book) (string, error) {
+	bucket, ok := r.buckets[BucketDocs]
+	if !ok || book.DocInfo == nil || book.DocInfo.ID == "" {
+		return "", nil
+	}
+
+	r.docsMu.Lock()
+	defer r.docsMu.Unlock()
+
+	var prev docRevision
+
+	data, err := bucket.Get([]byte(book.DocInfo.ID), nil)
+
+	switch {
+	case errors.Is(err, leveldb.ErrNotFound):
+		return "", r.putDocRevision(book.DocInfo.ID, r.newDocRevision(book, nil))
+	case err != nil:
+		return "", err
+	}
+
+	if err = r.decode(data, &prev); err != nil {
+		return "", err
+	}
+
+	cur := entities.NewDupCandidate(book, r.ResolveAuthorKey)
+	if prev.BookID != book.ID && !entities.SameDocRevisions(&cur, &entities.DupCandidate{
+		DocID: book.DocInfo.ID, Title: prev.Title, Authors: prev.Authors,
+	}) {
+		r.logger.Debug().Str("doc", book.DocInfo.ID).Str("book", book.ID).Str("prev", prev.BookID).
+			Msg("document id is shared by other book")
+
+		return "", nil
+	}
+
+	cmp := entities.CompareDocVersions(book.DocInfo.Version, prev.Version)
+
+	switch {
+	case prev.BookID == book.ID && cmp < 0:
+		return "", ErrOlderDocRevision
+	case prev.BookID == book.ID:
+		return "", r.putDocRevision(book.DocInfo.ID, r.newDocRevision(book, prev.Alts))
+	case cmp <= 0: // the first indexed copy of the same revision is kept
+		book.DupOf = prev.BookID
+		if !entities.SliceHasString(prev.Alts, book.ID) {
+			prev.Alts = append(prev.Alts, book.ID)
+		}
+
+		if err = r.putDocRevision(book.DocInfo.ID, prev); err != nil {
+			return "", err
+		}
+
+		r.queueDocLink(prev.BookID, docLink{alts: []string{book.ID}})
+
+		return prev.BookID, nil
+	}
+
+	(&docLink{alts: append([]string{prev.BookID}, prev.Alts...)}).apply(book)
+
+	if err = r.putDocRevision(book.DocInfo.ID, r.newDocRevision(book, book.Alternates)); err != nil {
+		return "", err
+	}
+
+	r.queueDocLink(prev.BookID, docLink{dupOf: book.ID})
+
+	return prev.BookID, nil
+}
+
+func (r *BooksLevelBleve) newDocRevision(book *entities.Book, alts []string) docRevision {
+	cur := entities.NewDupCandidate(book, r.ResolveAuthorKey)
+
+	return docRevision{
+		BookID: book.ID, Version: book.DocInfo.Version, Title: cur.Title, Authors: cur.Authors, Alts: alts,
+	}
+}
+
+func (r *BooksLevelBleve) putDocRevision(docID string, rev docRevision) error {
+	data, err := r.encode(rev)
+	if err != nil {
+		return err
+	}
+
+	return r.buckets[BucketDocs].Put([]byte(docID), data, nil)
+}
+
+// queueDocLink keeps the link until the revision is saved, docsMu should be locked by the caller.
+func (r *BooksLevelBleve) queueDocLink(bookID string, link docLink) {
+	if prev, ok := r.docsLinks[bookID]; ok && link.dupOf == "" {
+		prev.alts = append(prev.alts, link.alts...)
+		return
+	}
+
+	r.docsLinks[bookID] = &link
+}
+
+// takeDocLink returns and forgets the pending link of the book, which is going to be saved.
+func (r *BooksLevelBleve) takeDocLink(bookID string) *docLink {
+	r.docsMu.Lock()
+	defer r.docsMu.Unlock()
+
+	link := r.docsLinks[bookID]
+	delete(r.docsLinks, bookID)
+
+	return link
+}
+
+// applyDocLink updates already saved revision by its pending link: marks it as a duplicate of the newer one
+// or appends older ones to its alternates. The link is left for SaveBook, if the revision is not saved yet.
+func (r *BooksLevelBleve) applyDocLink(bookID string) {
+	r.docsMu.Lock()
+	defer r.docsMu.Unlock()
+
+	link, ok := r.docsLinks[bookID]
+	if !ok {
+		return
+	}
+
+	saved, err := r.GetByID(bookID)
+	if err != nil {
+		r.logger.Debug().Err(err).Str("book", bookID).Msg("previous document revision is not saved yet")
+		return
+	}
+
+	delete(r.docsLinks, bookID)
+	link.apply(saved)
+
+	if err = r.UpdateBooks([]*entities.Book{saved}); err != nil {
+		r.logger.Error().Err(err).Str("book", bookID).Msg("link document revision")
+	}
+}
+
+// removeDocRevisions forgets documents revisions of removed books, so other revisions of the documents could be
+// registered again, and removes removed books from known alternates of the documents.
+func (r *BooksLevelBleve) removeDocRevisions(removed map[string]struct{}) error {
+	bucket, ok := r.buckets[BucketDocs]
+	if !ok {
+		return nil
+	}
+
+	r.docsMu.Lock()
+	defer r.docsMu.Unlock()
+
+	for bookID := range removed {
+		delete(r.docsLinks, bookID)
+	}
+
+	batch := new(leveldb.Batch)
+	iter := bucket.NewIterator(nil, nil)
+
+	for iter.Next() {
+		var rev docRevision
+		if err := r.decode(iter.Value(), &rev); err != nil {
+			continue
+		}
+
+		if _, ok := removed[rev.BookID]; ok {
+			batch.Delete(iter.Key())
+			continue
+		}
+
+		alts := rev.Alts[:0]
+		for _, altID := range rev.Alts {
+			if _, ok := removed[altID]; !ok {
+				alts = append(alts, altID)
+			}
+		}
+
+		if len(alts) == len(rev.Alts) {
+			continue
+		}
+
+		rev.Alts = alts
+		if data, err := r.encode(rev); err == nil {
+			batch.Put(iter.Key(), data)
+		}
+	}
+
+	iter.Release()
+
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	return bucket.Write(batch, nil)
+}
@@ -0,0 +1,112 @@
+package repos
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/pkg/pagination"
+)
+
+type IndexFailures struct {
+	db     *leveldb.DB
+	encode entities.IMarshal
+	decode entities.IUnmarshal
+}
+
+func NewIndexFailures(db *leveldb.DB, encode entities.IMarshal, decode entities.IUnmarshal) *IndexFailures {
+	return &IndexFailures{
+		db:     db,
+		encode: encode,
+		decode: decode,
+	}
+}
+
+func (r *IndexFailures) key(lib, src string) []byte {
+	return []byte(lib + ":" + src)
+}
+
+func (r *IndexFailures) Add(item entities.IndexFailure) error {
+	data, err := r.encode(item)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Put(r.key(item.Lib, item.Src), data, nil)
+}
+
+// Remove deletes failure of the item, it is deleted only for the specified stages if they are passed.
+func (r *IndexFailures) Remove(lib, src string, stages ...string) error {
+	if len(stages) > 0 {
+		data, err := r.db.Get(r.key(lib, src), nil)
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var item entities.IndexFailure
+		if err = r.decode(data, &item); err != nil {
+			return err
+		}
+
+		for _, stage := range stages {
+			if stage == item.Stage {
+				return r.db.Delete(r.key(lib, src), nil)
+			}
+		}
+
+		return nil
+	}
+
+	return r.db.Delete(r.key(lib, src), nil)
+}
+
+// Find returns failures matching the filter, the newest ones go first.
+func (r *IndexFailures) Find(filter entities.IndexFailuresFilter, pager pagination.IPager) ([]entities.IndexFailure, error) {
+	var res []entities.IndexFailure
+
+	iter := r.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		var item entities.IndexFailure
+		if err := r.decode(iter.Value(), &item); err != nil {
+			return nil, err
+		}
+
+		if filter.Match(item) {
+			res = append(res, item)
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].TS.After(res[j].TS) })
+
+	if pager == nil {
+		return res, nil
+	}
+
+	pager.SetTotal(len(res))
+
+	if pager.GetOffset() >= len(res) {
+		return nil, nil
+	}
+
+	if len(res) < pager.GetOffset()+pager.GetPageSize() {
+		return res[pager.GetOffset():], nil
+	}
+
+	return res[pager.GetOffset() : pager.GetOffset()+pager.GetPageSize()], nil
+}
+
+func (r *IndexFailures) Close() error {
+	return r.db.Close()
+}
@@ -0,0 +1,86 @@
+package repos
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/pkg/pagination"
+)
+
+// IndexRuns stores reports of indexing runs by their IDs, which are sorted by start time.
+type IndexRuns struct {
+	db     *leveldb.DB
+	encode entities.IMarshal
+	decode entities.IUnmarshal
+}
+
+func NewIndexRuns(db *leveldb.DB, encode entities.IMarshal, decode entities.IUnmarshal) *IndexRuns {
+	return &IndexRuns{
+		db:     db,
+		encode: encode,
+		decode: decode,
+	}
+}
+
+func (r *IndexRuns) Save(run *entities.IndexRun) error {
+	data, err := r.encode(run)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Put([]byte(run.ID), data, nil)
+}
+
+func (r *IndexRuns) Get(id string) (*entities.IndexRun, error) {
+	data, err := r.db.Get([]byte(id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var res entities.IndexRun
+	if err = r.decode(data, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Find returns runs of the page, the newest ones go first. Only runs of the page are decoded.
+func (r *IndexRuns) Find(pager pagination.IPager) ([]entities.IndexRun, error) {
+	var (
+		res   []entities.IndexRun
+		total int
+	)
+
+	iter := r.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	for ok := iter.Last(); ok; ok = iter.Prev() {
+		total++
+
+		if pager != nil && (total <= pager.GetOffset() || total > pager.GetOffset()+pager.GetPageSize()) {
+			continue
+		}
+
+		var item entities.IndexRun
+		if err := r.decode(iter.Value(), &item); err != nil {
+			return nil, err
+		}
+
+		res = append(res, item)
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	if pager != nil {
+		pager.SetTotal(total)
+	}
+
+	return res, nil
+}
+
+func (r *IndexRuns) Close() error {
+	return r.db.Close()
+}
@@ -1,9 +1,14 @@
 package repos
 
 import (
+	"strconv"
+
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
+const checkpointPrefix = "checkpoint:"
+
 type LibMarks struct {
 	db *leveldb.DB
 }
@@ -23,8 +28,55 @@ func (r *LibMarks) MarkExists(mark string) bool {
 	return string(data) == "true"
 }
 
+// AddMark marks the item as indexed, checkpoint of the item is removed.
 func (r *LibMarks) AddMark(mark string) error {
-	return r.db.Put([]byte(mark), []byte("true"), nil)
+	batch := new(leveldb.Batch)
+	batch.Put([]byte(mark), []byte("true"))
+	batch.Delete([]byte(checkpointPrefix + mark))
+
+	return r.db.Write(batch, nil)
+}
+
+// GetCheckpoint returns offset of the last archive entry, which books are saved, zero is returned if there is no
+// checkpoint.
+func (r *LibMarks) GetCheckpoint(item string) uint64 {
+	data, err := r.db.Get([]byte(checkpointPrefix+item), nil)
+	if err != nil {
+		return 0
+	}
+
+	offset, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return offset
+}
+
+func (r *LibMarks) SetCheckpoint(item string, offset uint64) error {
+	return r.db.Put([]byte(checkpointPrefix+item), []byte(strconv.FormatUint(offset, 10)), nil)
+}
+
+// RemoveMarks removes mark and checkpoint of the item and marks of items inside of it.
+func (r *LibMarks) RemoveMarks(item string) error {
+	batch := new(leveldb.Batch)
+
+	for _, prefix := range []string{"", checkpointPrefix} {
+		batch.Delete([]byte(prefix + item))
+
+		iter := r.db.NewIterator(util.BytesPrefix([]byte(prefix+item+"/")), nil)
+		for iter.Next() {
+			batch.Delete(iter.Key())
+		}
+
+		iter.Release()
+
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+
+	return r.db.Write(batch, nil)
 }
 
 func (r *LibMarks) Close() error {
@@ -1,13 +1,12 @@
 package repos
 
 import (
-	"compress/flate"
+	"bytes"
+	"encoding/base64"
 	"encoding/xml"
 	"fmt"
 	"io"
-	"os"
-	"path"
-	"strings"
+	"sync"
 
 	"github.com/egnd/go-pipeline"
 	"github.com/egnd/go-pipeline/tasks"
@@ -19,6 +18,7 @@ import (
 )
 
 type LibraryFs struct {
+	mu       sync.RWMutex
 	libs     entities.Libraries
 	logger   zerolog.Logger
 	executor pipeline.Dispatcher
@@ -32,7 +32,26 @@ func NewLibraryFs(libs entities.Libraries, executor pipeline.Dispatcher, logger
 	}
 }
 
+// SetLibs replaces libraries, e.g. after config reload.
+func (r *LibraryFs) SetLibs(libs entities.Libraries) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.libs = libs
+}
+
+func (r *LibraryFs) getLibs() entities.Libraries {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.libs
+}
+
 func (r *LibraryFs) AppendFB2Book(book *entities.Book) error {
+	if book.Format() == entities.BookFormatEPUB {
+		return r.appendEPUBCover(book)
+	}
+
 	fb2File, err := r.readFB2(book, getBookCoverRule(book))
 	if err != nil {
 		return err
@@ -51,6 +70,31 @@ func (r *LibraryFs) AppendFB2Book(book *entities.Book) error {
 	return nil
 }
 
+func (r *LibraryFs) appendEPUBCover(book *entities.Book) error {
+	if book.Info.CoverID == "" {
+		return nil
+	}
+
+	epub, err := r.ReadEPUB(book)
+	if err != nil {
+		return err
+	}
+
+	data, err := epub.ReadFile(book.Info.CoverID)
+	if err != nil {
+		return err
+	}
+
+	_, contentType := epub.Cover()
+	book.Info.Cover = &fb2.Binary{
+		ID:          book.Info.CoverID,
+		ContentType: contentType,
+		Data:        base64.StdEncoding.EncodeToString(data),
+	}
+
+	return nil
+}
+
 func (r *LibraryFs) AppendFB2Books(books []entities.Book) {
 	for k := range books {
 		k := k
@@ -62,36 +106,49 @@ func (r *LibraryFs) AppendFB2Books(books []entities.Book) {
 	r.executor.Wait()
 }
 
-func (r *LibraryFs) readFB2(book *entities.Book, rules ...xmlparse.Rule) (*fb2.File, error) {
-	if book.Src == "" {
-		return nil, fmt.Errorf("libsfs repo err: empty book src [%s]", book.ID)
+// OpenBook returns reader of the book file, which is unpacked from archive if needed.
+func (r *LibraryFs) OpenBook(book *entities.Book) (io.ReadCloser, error) {
+	reader, err := r.getLibs().OpenBook(book)
+	if err != nil {
+		return nil, fmt.Errorf("libsfs repo err: %w", err)
 	}
 
-	lib, libExist := r.libs[book.Lib]
-	if !libExist {
-		return nil, fmt.Errorf("libsfs repo err: undefined lib name %s", book.Lib)
+	return reader, nil
+}
+
+func (r *LibraryFs) ReadEPUB(book *entities.Book) (*entities.EPUB, error) {
+	reader, err := r.OpenBook(book)
+	if err != nil {
+		return nil, err
 	}
+	defer reader.Close()
 
-	var fb2Stream io.ReadCloser
-	if strings.Contains(book.Src, ".zip") {
-		zipFile, err := os.Open(strings.Split(path.Join(lib.Dir, book.Src), ".zip")[0] + ".zip")
-		if err != nil {
-			return nil, err
-		}
-		defer zipFile.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
 
-		fb2Stream = flate.NewReader(io.NewSectionReader(zipFile, int64(book.Offset), int64(book.SizeCompressed)))
-	} else {
-		var err error
+	return entities.ParseEPUB(bytes.NewReader(data), int64(len(data)))
+}
 
-		if fb2Stream, err = os.Open(path.Join(lib.Dir, book.Src)); err != nil {
-			return nil, err
-		}
+func (r *LibraryFs) readFB2(book *entities.Book, rules ...xmlparse.Rule) (*fb2.File, error) {
+	fb2Stream, err := r.OpenBook(book)
+	if err != nil {
+		return nil, err
 	}
-
 	defer fb2Stream.Close()
 
-	res, err := entities.ParseFB2(fb2Stream, lib.Encoder, rules...)
+	raw, err := io.ReadAll(fb2Stream)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := entities.DecodeFB2(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := entities.ParseFB2(bytes.NewReader(data), r.getLibs()[book.Lib].Encoder, rules...)
 
 	return &res, err
 }
@@ -0,0 +1,13 @@
+package repos
+
+import (
+	"github.com/egnd/fb2lib/pkg/metrics"
+)
+
+var (
+	searchDuration = metrics.NewHistogram("fb2lib_search_duration_seconds", "Duration of books search.", nil, "kind")
+	searchHits     = metrics.NewHistogram("fb2lib_search_hits", "Count of books found by search.",
+		[]float64{0, 1, 10, 100, 1000, 10000, 100000}, "kind",
+	)
+	searchFailures = metrics.NewCounter("fb2lib_search_failures_total", "Count of failed books searches.", "kind")
+)
@@ -0,0 +1,28 @@
+package response
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/labstack/echo/v4"
+)
+
+var bookMimeTypes = map[string]string{
+	entities.BookFormatFB2:  "application/fb2",
+	entities.BookFormatEPUB: "application/epub+zip",
+}
+
+func BookFromLocalArchive(book *entities.Book, libs entities.Libraries, server echo.Context) error {
+	reader, err := libs.OpenBook(book)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	server.Response().Header().Set(echo.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="%s.%s"`, entities.BuildBookName(book), book.Format()),
+	)
+
+	return server.Stream(http.StatusOK, bookMimeTypes[book.Format()], reader)
+}
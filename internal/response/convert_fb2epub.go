@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/egnd/fb2lib/internal/entities"
 	"github.com/labstack/echo/v4"
@@ -29,12 +30,16 @@ func ConvertFB2Epub(converterDir string, book *entities.Book,
 
 	logger.Info().Str("epub", epubPath).Str("cmd", cmd.String()).Msg("fb2epub")
 
+	start := time.Now()
 	out, err := cmd.CombinedOutput()
+	conversionDuration.ObserveSince(start, entities.BookFormatEPUB)
+
 	if _, existsErr := os.Stat(epubPath); existsErr != nil {
 		logger.Error().Str("out", string(out)).Msg("fb2c output")
 	}
 
 	if err != nil {
+		conversionFailures.Inc(entities.BookFormatEPUB)
 		return err
 	}
 
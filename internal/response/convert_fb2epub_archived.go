@@ -1,45 +1,34 @@
 package response
 
 import (
-	"compress/flate"
-	"errors"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path"
-	"strings"
+	"time"
 
 	"github.com/egnd/fb2lib/internal/entities"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
 )
 
-func ConvertFB2EpubZipOffset(converterDir string, book *entities.Book,
+func ConvertFB2EpubArchived(converterDir string, book *entities.Book,
 	libs entities.Libraries, server echo.Context, logger zerolog.Logger,
 ) error {
 	epubPath := path.Join(converterDir, book.ID+".epub")
 	if _, err := os.Stat(epubPath); err == nil {
+		conversionCacheRequests.Inc("hit")
 		return server.File(epubPath)
 	}
 
+	conversionCacheRequests.Inc("miss")
+
 	fb2Path := path.Join(converterDir, book.ID+".fb2")
 	if _, err := os.Stat(fb2Path); err != nil {
-		archivePath := strings.Split(book.Src, ".zip")[0] + ".zip"
-		if lib, ok := libs[book.Lib]; ok {
-			archivePath = path.Join(lib.Dir, archivePath)
-		} else {
-			server.NoContent(http.StatusInternalServerError)
-			return errors.New("can't define book library")
-		}
-
-		zipFile, err := os.Open(archivePath)
+		fb2Stream, err := libs.OpenBook(book)
 		if err != nil {
 			return err
 		}
-		defer zipFile.Close()
-
-		fb2Stream := flate.NewReader(io.NewSectionReader(zipFile, int64(book.Offset), int64(book.SizeCompressed)))
 		defer fb2Stream.Close()
 
 		tmpFB2File, err := os.Create(fb2Path)
@@ -55,15 +44,19 @@ func ConvertFB2EpubZipOffset(converterDir string, book *entities.Book,
 	defer os.Remove(fb2Path)
 
 	cmd := exec.Command("bin/fb2c", "convert", "--to=epub", fb2Path, converterDir)
+	start := time.Now()
 
-	logger.Info().Str("fb2", fb2Path).Str("epub", epubPath).Str("cmd", cmd.String()).Msg("fb2epub zip offset")
+	logger.Info().Str("fb2", fb2Path).Str("epub", epubPath).Str("cmd", cmd.String()).Msg("fb2epub archived")
 
 	out, err := cmd.CombinedOutput()
+	conversionDuration.ObserveSince(start, entities.BookFormatEPUB)
+
 	if _, existsErr := os.Stat(epubPath); existsErr != nil {
 		logger.Error().Str("out", string(out)).Msg("fb2c output")
 	}
 
 	if err != nil {
+		conversionFailures.Inc(entities.BookFormatEPUB)
 		return err
 	}
 
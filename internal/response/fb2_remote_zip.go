@@ -1,9 +1,9 @@
 package response
 
 import (
+	"archive/zip"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/egnd/fb2lib/internal/entities"
 	"github.com/labstack/echo/v4"
@@ -12,9 +12,9 @@ import (
 func FB2FromRemoteZip(urlPrefix, libDir string, book *entities.Book,
 	server echo.Context, client *http.Client,
 ) error {
-	req, err := http.NewRequest(http.MethodGet, entities.BuildBookURL(
-		strings.Split(book.Src, ".zip")[0]+".zip", urlPrefix, libDir,
-	), nil)
+	archive, _ := book.Archive()
+
+	req, err := http.NewRequest(http.MethodGet, entities.BuildBookURL(archive, urlPrefix, libDir), nil)
 	if err != nil {
 		return err
 	}
@@ -32,7 +32,10 @@ func FB2FromRemoteZip(urlPrefix, libDir string, book *entities.Book,
 		return fmt.Errorf("remote zip response error: %s", resp.Status)
 	}
 
-	server.Response().Header().Set(echo.HeaderContentEncoding, "deflate")
+	if book.Locator().ZipMethod() == zip.Deflate {
+		server.Response().Header().Set(echo.HeaderContentEncoding, "deflate")
+	}
+
 	server.Response().Header().Set(echo.HeaderContentDisposition,
 		fmt.Sprintf(`attachment; filename="%s.fb2"`, entities.BuildBookName(book)),
 	)
@@ -0,0 +1,17 @@
+package response
+
+import (
+	"github.com/egnd/fb2lib/pkg/metrics"
+)
+
+var (
+	conversionDuration = metrics.NewHistogram("fb2lib_conversion_duration_seconds", "Duration of books conversion.",
+		[]float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60}, "format",
+	)
+	conversionFailures = metrics.NewCounter("fb2lib_conversion_failures_total",
+		"Count of failed books conversions.", "format",
+	)
+	conversionCacheRequests = metrics.NewCounter("fb2lib_conversion_cache_requests_total",
+		"Count of converted books cache requests by result (hit or miss).", "result",
+	)
+)
@@ -1,15 +1,18 @@
 package tasks
 
 import (
+	"archive/zip"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"strings"
 
 	"github.com/egnd/fb2lib/internal/entities"
 	"github.com/egnd/fb2lib/internal/repos"
+	"github.com/egnd/fb2lib/pkg/archive"
 	"github.com/pkg/errors"
-	"github.com/vbauerster/mpb/v7"
 )
 
 type ErrAlreadyIndexed struct{}
@@ -19,31 +22,31 @@ func (e ErrAlreadyIndexed) Error() string {
 }
 
 type DefineItemTask struct {
-	id        string
-	item      string
-	lib       entities.Library
-	repoMarks *repos.LibMarks
-	bar       *mpb.Bar
-	doFB2Task PushReadTask
-	doZIPTask DoReadZipTask
+	id            string
+	item          string
+	lib           entities.Library
+	repoMarks     *repos.LibMarks
+	bar           ProgressBar
+	doFB2Task     PushReadTask
+	doArchiveTask DoReadArchiveTask
 }
 
 func NewDefineItemTask(
 	item string,
 	lib entities.Library,
 	repoMarks *repos.LibMarks,
-	bar *mpb.Bar,
+	bar ProgressBar,
 	doFB2Task PushReadTask,
-	doZIPTask DoReadZipTask,
+	doArchiveTask DoReadArchiveTask,
 ) *DefineItemTask {
 	return &DefineItemTask{
-		id:        fmt.Sprintf("look at [%s] %s", lib.Name, strings.TrimPrefix(item, lib.Dir)),
-		item:      item,
-		lib:       lib,
-		repoMarks: repoMarks,
-		bar:       bar,
-		doFB2Task: doFB2Task,
-		doZIPTask: doZIPTask,
+		id:            fmt.Sprintf("look at [%s] %s", lib.Name, strings.TrimPrefix(item, lib.Dir)),
+		item:          item,
+		lib:           lib,
+		repoMarks:     repoMarks,
+		bar:           bar,
+		doFB2Task:     doFB2Task,
+		doArchiveTask: doArchiveTask,
 	}
 }
 
@@ -51,6 +54,10 @@ func (t *DefineItemTask) ID() string {
 	return t.id
 }
 
+func (t *DefineItemTask) FailedItem() entities.Book {
+	return entities.Book{Lib: t.lib.Name, Src: strings.TrimPrefix(t.item, t.lib.Dir)}
+}
+
 func (t *DefineItemTask) Do() error {
 	finfo, err := os.Stat(t.item)
 	if err != nil {
@@ -65,19 +72,29 @@ func (t *DefineItemTask) Do() error {
 		return &ErrAlreadyIndexed{}
 	}
 
-	switch path.Ext(t.item) {
-	case ".zip":
-		if err := t.doZIPTask(finfo); err != nil {
-			return errors.Wrap(err, "do zip error")
+	switch {
+	case entities.BookContainer(t.item) != "":
+		archived, err := t.readContainer(finfo)
+		if err != nil {
+			return errors.Wrap(err, "do container error")
+		}
+
+		if archived {
+			return nil
 		}
 
 		if err := t.repoMarks.AddMark(t.item); err != nil {
 			return errors.Wrap(err, "memorize item error")
 		}
-	case ".fb2":
+	case archive.KindOf(t.item) != "":
+		// archive task marks the archive after books of its entries are saved
+		if err := t.doArchiveTask(finfo); err != nil {
+			return errors.Wrap(err, "do archive error")
+		}
+	case path.Ext(t.item) == ".fb2" || path.Ext(t.item) == ".epub":
 		reader, err := os.Open(t.item)
 		if err != nil {
-			return errors.Wrap(err, "open fb2 error")
+			return errors.Wrap(err, "open book error")
 		}
 
 		if err := t.doFB2Task(reader, entities.Book{
@@ -85,7 +102,7 @@ func (t *DefineItemTask) Do() error {
 			Size: uint64(finfo.Size()),
 			Src:  strings.TrimPrefix(t.item, t.lib.Dir),
 		}); err != nil {
-			return errors.Wrap(err, "do fb2 error")
+			return errors.Wrap(err, "do book error")
 		}
 
 		if err := t.repoMarks.AddMark(t.item); err != nil {
@@ -97,3 +114,60 @@ func (t *DefineItemTask) Do() error {
 
 	return nil
 }
+
+// readContainer reads compressed single book file, zip containers with several items are read as archives.
+func (t *DefineItemTask) readContainer(finfo os.FileInfo) (archived bool, err error) {
+	file, err := os.Open(t.item)
+	if err != nil {
+		return false, errors.Wrap(err, "open container error")
+	}
+
+	defer func() {
+		if err != nil {
+			file.Close()
+		}
+	}()
+
+	book := entities.Book{
+		Lib:            t.lib.Name,
+		SizeCompressed: uint64(finfo.Size()),
+		Src:            strings.TrimPrefix(t.item, t.lib.Dir),
+	}
+
+	var reader io.ReadCloser
+
+	switch entities.BookContainer(t.item) {
+	case entities.BookContainerZip:
+		var items *zip.Reader
+		if items, err = zip.NewReader(file, finfo.Size()); err != nil {
+			return false, errors.Wrap(err, "read zip error")
+		}
+
+		if len(items.File) != 1 {
+			file.Close()
+			return true, t.doArchiveTask(finfo)
+		}
+
+		var loc archive.Locator
+		if loc, err = archive.ZipLocator(items.File[0]); err != nil {
+			return false, errors.Wrap(err, "locate item error")
+		}
+
+		if reader, err = archive.ExtractZip(file, loc); err != nil {
+			return false, errors.Wrap(err, "extract item error")
+		}
+
+		book.Offset, book.Size, book.SizeCompressed = loc.Offset, loc.Size, loc.SizeCompressed
+		book.Method, book.CRC32 = loc.Method, loc.CRC32
+	case entities.BookContainerGzip:
+		if book.Size, err = entities.GzipSize(file, finfo.Size()); err != nil {
+			return false, errors.Wrap(err, "gzip size error")
+		}
+
+		if reader, err = gzip.NewReader(file); err != nil {
+			return false, errors.Wrap(err, "read gzip error")
+		}
+	}
+
+	return false, t.doFB2Task(&entities.ArchiveItemReader{ReadCloser: reader, Archive: file}, book)
+}
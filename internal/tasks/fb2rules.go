@@ -2,9 +2,12 @@ package tasks
 
 import (
 	"encoding/xml"
+	"strings"
 
 	"github.com/egnd/go-xmlparse"
 	"github.com/egnd/go-xmlparse/fb2"
+
+	"github.com/egnd/fb2lib/internal/entities"
 )
 
 func SkipFB2Binaries(next xmlparse.TokenHandler) xmlparse.TokenHandler {
@@ -46,3 +49,95 @@ func SkipFB2Cover(next xmlparse.TokenHandler) xmlparse.TokenHandler {
 		return next(obj, node, r)
 	}
 }
+
+// ReadFB2Annotation keeps annotation markup, which is dropped by fb2 package parser.
+func ReadFB2Annotation(next xmlparse.TokenHandler) xmlparse.TokenHandler {
+	return func(obj interface{}, node xml.StartElement, r xmlparse.TokenReader) error {
+		info, ok := obj.(*fb2.TitleInfo)
+		if !ok || node.Name.Local != "annotation" {
+			return next(obj, node, r)
+		}
+
+		var buf strings.Builder
+
+		for depth := 0; ; {
+			token, err := r.Token()
+			if err != nil {
+				return err
+			}
+
+			switch typed := token.(type) {
+			case xml.StartElement:
+				depth++
+
+				buf.WriteString("<" + typed.Name.Local)
+				for _, attr := range typed.Attr {
+					buf.WriteString(" " + attr.Name.Local + `="`)
+					xml.EscapeText(&buf, []byte(attr.Value))
+					buf.WriteString(`"`)
+				}
+				buf.WriteString(">")
+			case xml.EndElement:
+				if depth == 0 {
+					info.Annotation = append(info.Annotation, fb2.Annotation{HTML: strings.TrimSpace(buf.String())})
+					return nil
+				}
+
+				depth--
+
+				buf.WriteString("</" + typed.Name.Local + ">")
+			case xml.CharData:
+				xml.EscapeText(&buf, typed)
+			}
+		}
+	}
+}
+
+// FB2DocInfoExtra captures document-info nodes, which are not parsed by fb2 package.
+type FB2DocInfoExtra struct {
+	Program string
+	Date    string
+}
+
+func (e *FB2DocInfoExtra) Rule(next xmlparse.TokenHandler) xmlparse.TokenHandler {
+	return func(obj interface{}, node xml.StartElement, r xmlparse.TokenReader) (err error) {
+		if _, ok := obj.(*fb2.DocInfo); !ok {
+			return next(obj, node, r)
+		}
+
+		var strVal string
+
+		switch node.Name.Local {
+		case "program-used":
+			if strVal, err = xmlparse.TokenRead(node.Name.Local, r); err == nil && e.Program == "" {
+				e.Program = strings.TrimSpace(strVal)
+			}
+		case "date":
+			if strVal, err = xmlparse.TokenRead(node.Name.Local, r); err == nil && e.Date == "" {
+				e.Date = strings.TrimSpace(strVal)
+
+				for _, attr := range node.Attr {
+					if attr.Name.Local == "value" && attr.Value != "" {
+						e.Date = attr.Value
+					}
+				}
+			}
+		default:
+			return next(obj, node, r)
+		}
+
+		return
+	}
+}
+
+func (e *FB2DocInfoExtra) Apply(book *entities.Book) {
+	if e.Program == "" && e.Date == "" {
+		return
+	}
+
+	if book.DocInfo == nil {
+		book.DocInfo = &entities.BookDocInfo{}
+	}
+
+	book.DocInfo.Program, book.DocInfo.Date = e.Program, e.Date
+}
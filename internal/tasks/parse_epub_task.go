@@ -0,0 +1,50 @@
+package tasks
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/internal/repos"
+)
+
+type ParseEPUBTask struct {
+	*ParseFB2Task
+}
+
+func NewParseEPUBTask(
+	data io.Reader,
+	book entities.Book,
+	rules *entities.IndexRules,
+	repo *repos.BooksLevelBleve,
+	bar ProgressBar,
+	logger zerolog.Logger,
+	detectLang bool,
+) *ParseEPUBTask {
+	task := NewParseFB2Task(data, book, rules, "", repo, bar, logger, detectLang)
+	task.id = fmt.Sprintf("parse epub [%s] %s", book.Lib, book.Src)
+
+	return &ParseEPUBTask{task}
+}
+
+func (t *ParseEPUBTask) Do() error {
+	defer t.progress()
+
+	data, err := io.ReadAll(t.data)
+	if err != nil {
+		return errors.Wrap(err, "read epub error")
+	}
+
+	epub, err := entities.ParseEPUB(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return errors.Wrap(err, "parse epub error")
+	}
+
+	t.book.ReadEPUB(epub)
+
+	return t.save()
+}
@@ -1,11 +1,12 @@
 package tasks
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 
 	"github.com/pkg/errors"
-	"github.com/vbauerster/mpb/v7"
+	"github.com/rs/zerolog"
 
 	"github.com/egnd/fb2lib/internal/entities"
 	"github.com/egnd/fb2lib/internal/repos"
@@ -20,6 +21,10 @@ func (e ErrSkipRule) Error() string {
 	return fmt.Sprintf("skip: %s - %s", e.err, e.book)
 }
 
+func (e ErrSkipRule) Unwrap() error {
+	return e.err
+}
+
 type PushParseTask func(io.Reader) error
 
 type ParseFB2Task struct {
@@ -28,17 +33,21 @@ type ParseFB2Task struct {
 	book    entities.Book
 	encoder entities.LibEncodeType
 	repo    *repos.BooksLevelBleve
-	bar     *mpb.Bar
-	rules   entities.IndexRules
+	bar     ProgressBar
+	rules   *entities.IndexRules
+	logger  zerolog.Logger
+	detect  bool
 }
 
 func NewParseFB2Task(
 	data io.Reader,
 	book entities.Book,
-	rules entities.IndexRules,
+	rules *entities.IndexRules,
 	encoder entities.LibEncodeType,
 	repo *repos.BooksLevelBleve,
-	bar *mpb.Bar,
+	bar ProgressBar,
+	logger zerolog.Logger,
+	detectLang bool,
 ) *ParseFB2Task {
 	return &ParseFB2Task{
 		id:      fmt.Sprintf("parse [%s] %s", book.Lib, book.Src),
@@ -48,6 +57,8 @@ func NewParseFB2Task(
 		repo:    repo,
 		bar:     bar,
 		rules:   rules,
+		logger:  logger,
+		detect:  detectLang,
 	}
 }
 
@@ -55,29 +66,90 @@ func (t *ParseFB2Task) ID() string {
 	return t.id
 }
 
+func (t *ParseFB2Task) FailedItem() entities.Book {
+	return t.book
+}
+
 func (t *ParseFB2Task) Do() error {
-	if t.bar != nil {
-		if t.book.SizeCompressed > 0 {
-			defer t.bar.IncrInt64(int64(t.book.SizeCompressed))
-		} else {
-			defer t.bar.IncrInt64(int64(t.book.Size))
-		}
+	defer t.progress()
+
+	raw, err := io.ReadAll(t.data)
+	if err != nil {
+		return errors.Wrap(err, "read fb2 error")
 	}
 
-	fb2File, err := entities.ParseFB2(t.data, t.encoder, SkipFB2DocInfo, SkipFB2CustomInfo, SkipFB2Binaries)
+	declared, err := ReadFB2Book(raw, &t.book, t.encoder)
+	if err != nil {
+		return err
+	}
 
+	if declared != "" {
+		t.logger.Warn().Str("task", t.id).Str("declared", declared).Str("detected", t.book.Encoding).Msg("wrong encoding")
+	}
+
+	return t.save()
+}
+
+// ReadFB2Book fills the book with data of fb2 file, declared encoding of the file is returned if it differs from
+// the detected one.
+func ReadFB2Book(raw []byte, book *entities.Book, encoder entities.LibEncodeType) (wrongEncoding string, err error) {
+	data, encoding, err := entities.DecodeFB2(raw)
 	if err != nil {
-		return errors.Wrap(err, "parse fb2 error")
+		return "", errors.Wrap(err, "decode fb2 error")
+	}
+
+	if declared := entities.DeclaredEncoding(raw); declared != "" && declared != encoding {
+		wrongEncoding = declared
 	}
 
-	t.book.ReadFB2(&fb2File)
+	var docExtra FB2DocInfoExtra
+
+	fb2File, err := entities.ParseFB2(bytes.NewReader(data), encoder, docExtra.Rule, ReadFB2Annotation, SkipFB2Binaries)
+
+	if err != nil {
+		return "", errors.Wrap(err, "parse fb2 error")
+	}
+
+	book.ReadFB2(&fb2File)
+	book.Encoding = encoding
+	docExtra.Apply(book)
+
+	return
+}
+
+func (t *ParseFB2Task) progress() {
+	if t.bar == nil {
+		return
+	}
+
+	if t.book.SizeCompressed > 0 {
+		t.bar.IncrInt64(int64(t.book.SizeCompressed))
+	} else {
+		t.bar.IncrInt64(int64(t.book.Size))
+	}
+}
+
+func (t *ParseFB2Task) save() error {
+	if t.detect && t.book.DetectLang() {
+		t.logger.Debug().Str("task", t.id).Str("lang", t.book.Info.Lang).Str("orig", t.book.Info.LangOrig).Msg("lang detected")
+	}
+
+	if unknown := entities.UnknownGenres(t.book.Genres()); len(unknown) > 0 {
+		t.logger.Warn().Str("task", t.id).Strs("genres", unknown).Msg("unknown genres")
+	}
 
 	if err := t.rules.Check(&t.book); err != nil {
 		return &ErrSkipRule{t.book.Info.Title, err}
 	}
 
-	if err = t.repo.SaveBook(&t.book); err != nil {
-		return errors.Wrap(err, "index fb2 error")
+	if err := t.repo.SaveDocRevision(&t.book); errors.Is(err, repos.ErrOlderDocRevision) {
+		return &ErrSkipRule{t.book.Info.Title, err}
+	} else if err != nil {
+		return errors.Wrap(err, "save doc revision error")
+	}
+
+	if err := t.repo.SaveBook(&t.book); err != nil {
+		return errors.Wrap(err, "index book error")
 	}
 
 	return nil
@@ -0,0 +1,176 @@
+package tasks
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/egnd/fb2lib/internal/entities"
+	"github.com/egnd/fb2lib/pkg/archive"
+	"github.com/pkg/errors"
+	"github.com/vbauerster/mpb/v7"
+	"github.com/vbauerster/mpb/v7/decor"
+)
+
+type DoReadArchiveTask func(fs.FileInfo) error
+
+type ReadArchiveTask struct {
+	num          int
+	total        int
+	id           string
+	path         string
+	kind         string
+	item         fs.FileInfo
+	lib          entities.Library
+	bars         *mpb.Progress
+	progress     ProgressBar
+	checkpoint   uint64
+	doReaderTask PushReadTask
+}
+
+func NewReadArchiveTask(
+	num int,
+	total int,
+	path string,
+	item fs.FileInfo,
+	lib entities.Library,
+	bars *mpb.Progress,
+	progress ProgressBar,
+	checkpoint uint64,
+	doReaderTask PushReadTask,
+) *ReadArchiveTask {
+	kind := archive.KindOf(path)
+
+	return &ReadArchiveTask{
+		id:           fmt.Sprintf("iterate %s [%s] %s", kind, lib.Name, strings.TrimPrefix(path, lib.Dir)),
+		num:          num,
+		total:        total,
+		path:         path,
+		kind:         kind,
+		item:         item,
+		lib:          lib,
+		bars:         bars,
+		progress:     progress,
+		checkpoint:   checkpoint,
+		doReaderTask: doReaderTask,
+	}
+}
+
+func (t *ReadArchiveTask) ID() string {
+	return t.id
+}
+
+func (t *ReadArchiveTask) Do() error {
+	archiveFile, err := os.Open(t.path)
+	if err != nil {
+		return errors.Wrap(err, "open archive error")
+	}
+
+	// items readers are read by other tasks, so the file is closed after the walk and all of the readers are closed
+	refs := &archiveRefs{file: archiveFile, cnt: 1}
+	defer refs.release()
+
+	itemsReader, err := archive.New(t.kind, archiveFile, t.item.Size())
+	if err != nil {
+		return errors.Wrapf(err, "read %s error", t.path)
+	}
+
+	var bar *mpb.Bar
+	if t.bars != nil {
+		bar = t.initBar()
+		defer bar.Abort(true)
+	}
+
+	return itemsReader.Walk(func(item archive.Item, reader io.ReadCloser) error {
+		// entries are written to archives one by one, so entries before the checkpoint have lower offsets
+		if item.Offset <= t.checkpoint {
+			reader.Close()
+			t.skip(bar, item)
+
+			return nil
+		}
+
+		reader = refs.wrap(reader)
+
+		if err := t.doReaderTask(reader, entities.Book{
+			Offset:         item.Offset,
+			Size:           item.Size,
+			SizeCompressed: item.SizeCompressed,
+			Method:         item.Method,
+			CRC32:          item.CRC32,
+			Lib:            t.lib.Name,
+			Src:            path.Join(strings.TrimPrefix(t.path, t.lib.Dir), item.Name),
+		}); err != nil {
+			reader.Close()
+			return errors.Wrap(err, "do item error")
+		}
+
+		if bar != nil {
+			bar.IncrInt64(int64(item.SizeCompressed))
+		}
+
+		return nil
+	})
+}
+
+func (t *ReadArchiveTask) skip(bar *mpb.Bar, item archive.Item) {
+	if bar != nil {
+		bar.IncrInt64(int64(item.SizeCompressed))
+	}
+
+	if t.progress != nil {
+		t.progress.IncrInt64(int64(item.SizeCompressed))
+	}
+}
+
+func (t *ReadArchiveTask) initBar() *mpb.Bar {
+	return t.bars.AddBar(t.item.Size(),
+		mpb.BarRemoveOnComplete(),
+		mpb.PrependDecorators(decor.Name(fmt.Sprintf("[%d of %d] %s:%s",
+			t.num, t.total, t.lib.Name, strings.TrimPrefix(t.path, t.lib.Dir),
+		))),
+		mpb.AppendDecorators(
+			decor.CountersKibiByte("% .2f/% .2f"), decor.Name(", "),
+			decor.AverageSpeed(decor.UnitKB, "% .2f"), decor.Name(", "),
+			decor.AverageETA(decor.ET_STYLE_GO),
+		),
+	)
+}
+
+// archiveRefs counts users of the archive file and closes it after the last one is done.
+type archiveRefs struct {
+	file *os.File
+	cnt  int64
+}
+
+func (r *archiveRefs) wrap(reader io.ReadCloser) io.ReadCloser {
+	atomic.AddInt64(&r.cnt, 1)
+
+	return &archiveItemReader{ReadCloser: reader, refs: r}
+}
+
+func (r *archiveRefs) release() {
+	if atomic.AddInt64(&r.cnt, -1) == 0 {
+		r.file.Close()
+	}
+}
+
+type archiveItemReader struct {
+	io.ReadCloser
+	refs *archiveRefs
+	once sync.Once
+}
+
+func (r *archiveItemReader) Close() (err error) {
+	r.once.Do(func() {
+		err = r.ReadCloser.Close()
+		r.refs.release()
+	})
+
+	return
+}
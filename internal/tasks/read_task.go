@@ -13,18 +13,19 @@ type PushReadTask func(io.ReadCloser, entities.Book) error
 
 type ReadTask struct {
 	id          string
+	book        entities.Book
 	reader      io.ReadCloser
 	doParseTask PushParseTask
 }
 
 func NewReadTask(
-	src string,
-	lib string,
+	book entities.Book,
 	reader io.ReadCloser,
 	doParseTask PushParseTask,
 ) *ReadTask {
 	return &ReadTask{
-		id:          fmt.Sprintf("read [%s] %s", lib, src),
+		id:          fmt.Sprintf("read [%s] %s", book.Lib, book.Src),
+		book:        book,
 		reader:      reader,
 		doParseTask: doParseTask,
 	}
@@ -34,6 +35,10 @@ func (t *ReadTask) ID() string {
 	return t.id
 }
 
+func (t *ReadTask) FailedItem() entities.Book {
+	return t.book
+}
+
 func (t *ReadTask) Do() error {
 	defer t.reader.Close()
 
@@ -9,3 +9,13 @@ import (
 )
 
 type IndexTaskFactory func(io.Reader, entities.Book, zerolog.Logger) pipeline.Task
+
+// FailedItem is implemented by tasks, which failures are memorized to retry them later.
+type FailedItem interface {
+	FailedItem() entities.Book
+}
+
+// ProgressBar is incremented by sizes of processed library items.
+type ProgressBar interface {
+	IncrInt64(int64)
+}
@@ -0,0 +1,125 @@
+// Package archive reads items of book archives and extracts single items by their locators.
+//
+// Zip, tar and gzipped tar archives are supported. 7z is not, because there is no pure-Go decoder
+// for its LZMA based codecs among the project dependencies.
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	KindZip   = "zip"
+	KindTar   = "tar"
+	KindTarGz = "tgz"
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported archive")
+	ErrChecksum    = errors.New("archive item checksum error")
+)
+
+// Locator points to item data at the archive, so the item could be extracted without reading the whole archive.
+type Locator struct {
+	// Offset of item data at the archive, it is offset at the uncompressed stream for gzipped tars.
+	Offset uint64
+	Size   uint64
+	// SizeCompressed is size of item data at the archive file, it is approximate for gzipped tars.
+	SizeCompressed uint64
+	// Method is zip compression method of the item.
+	Method uint16
+	// CRC32 is checksum of uncompressed item data, zero value disables verification.
+	CRC32 uint32
+}
+
+type Item struct {
+	Name string
+	Locator
+}
+
+// ItemHandler receives archive item with its reader, which doesn't depend on the walking, so it could be read
+// later or concurrently while the archive file is opened.
+type ItemHandler func(Item, io.ReadCloser) error
+
+type Reader interface {
+	Walk(ItemHandler) error
+	Extract(Locator) (io.ReadCloser, error)
+}
+
+type Factory func(data io.ReaderAt, size int64) (Reader, error)
+
+var (
+	factories = map[string]Factory{
+		KindZip:   NewZip,
+		KindTar:   NewTar,
+		KindTarGz: NewTarGz,
+	}
+	suffixes = map[string]string{
+		".zip":    KindZip,
+		".tar":    KindTar,
+		".tar.gz": KindTarGz,
+		".tgz":    KindTarGz,
+	}
+)
+
+// Register adds archive reader factory for files with the suffixes.
+func Register(kind string, factory Factory, suffixList ...string) {
+	factories[kind] = factory
+
+	for _, suffix := range suffixList {
+		suffixes[strings.ToLower(suffix)] = kind
+	}
+}
+
+// New returns archive reader of the kind.
+func New(kind string, data io.ReaderAt, size int64) (Reader, error) {
+	factory, ok := factories[kind]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, kind)
+	}
+
+	return factory(data, size)
+}
+
+// KindOf returns archive kind by file name, empty string is returned for unknown archives.
+func KindOf(name string) string {
+	name = strings.ToLower(name)
+
+	var res string
+	var matched int
+
+	for suffix, kind := range suffixes {
+		if strings.HasSuffix(name, suffix) && len(suffix) > matched {
+			res, matched = kind, len(suffix)
+		}
+	}
+
+	return res
+}
+
+// Split splits path of archive item to archive path and item name.
+func Split(src string) (archivePath string, kind string, item string) {
+	lower := strings.ToLower(src)
+	pos, matched := -1, 0
+
+	for suffix, suffixKind := range suffixes {
+		idx := strings.Index(lower, suffix+"/")
+		if idx < 0 {
+			continue
+		}
+
+		// the outermost archive wins, the longest suffix wins for the same archive
+		if end := idx + len(suffix); pos < 0 || end < pos || (end == pos && len(suffix) > matched) {
+			pos, kind, matched = end, suffixKind, len(suffix)
+		}
+	}
+
+	if pos < 0 {
+		return "", "", ""
+	}
+
+	return src[:pos], kind, src[pos+1:]
+}
@@ -0,0 +1,125 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type Tar struct {
+	data io.ReaderAt
+	size int64
+}
+
+func NewTar(data io.ReaderAt, size int64) (Reader, error) {
+	return &Tar{data: data, size: size}, nil
+}
+
+func (t *Tar) Walk(handler ItemHandler) error {
+	stream := &countingReader{reader: io.NewSectionReader(t.data, 0, t.size)}
+
+	return walkTar(stream, func(item Item, _ io.Reader) error {
+		item.SizeCompressed = item.Size
+
+		return handler(item, io.NopCloser(io.NewSectionReader(t.data, int64(item.Offset), int64(item.Size))))
+	})
+}
+
+func (t *Tar) Extract(loc Locator) (io.ReadCloser, error) {
+	return io.NopCloser(io.NewSectionReader(t.data, int64(loc.Offset), int64(loc.Size))), nil
+}
+
+// TarGz reads gzipped tars. The gzip stream can't be seeked, so items are read into memory while walking and
+// extraction decompresses the archive up to the item.
+type TarGz struct {
+	data io.ReaderAt
+	size int64
+}
+
+func NewTarGz(data io.ReaderAt, size int64) (Reader, error) {
+	return &TarGz{data: data, size: size}, nil
+}
+
+func (t *TarGz) Walk(handler ItemHandler) error {
+	packed := &countingReader{reader: io.NewSectionReader(t.data, 0, t.size)}
+
+	gz, err := gzip.NewReader(packed)
+	if err != nil {
+		return fmt.Errorf("tgz error: %w", err)
+	}
+	defer gz.Close()
+
+	var prevPacked uint64
+
+	return walkTar(&countingReader{reader: gz}, func(item Item, data io.Reader) error {
+		content, err := io.ReadAll(data)
+		if err != nil {
+			return fmt.Errorf("tgz %s error: %w", item.Name, err)
+		}
+
+		item.SizeCompressed, prevPacked = packed.pos-prevPacked, packed.pos
+
+		return handler(item, io.NopCloser(bytes.NewReader(content)))
+	})
+}
+
+func (t *TarGz) Extract(loc Locator) (io.ReadCloser, error) {
+	gz, err := gzip.NewReader(io.NewSectionReader(t.data, 0, t.size))
+	if err != nil {
+		return nil, fmt.Errorf("tgz error: %w", err)
+	}
+
+	if _, err = io.CopyN(io.Discard, gz, int64(loc.Offset)); err != nil {
+		gz.Close()
+		return nil, fmt.Errorf("tgz seek error: %w", err)
+	}
+
+	return &limitedReadCloser{Reader: io.LimitReader(gz, int64(loc.Size)), Closer: gz}, nil
+}
+
+func walkTar(stream *countingReader, handler func(Item, io.Reader) error) error {
+	reader := tar.NewReader(stream)
+
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("tar error: %w", err)
+		}
+
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		// tar reader consumes whole headers only, so the stream position is the item data offset
+		if err := handler(Item{Name: header.Name, Locator: Locator{
+			Offset: stream.pos,
+			Size:   uint64(header.Size),
+		}}, reader); err != nil {
+			return err
+		}
+	}
+}
+
+type countingReader struct {
+	reader io.Reader
+	pos    uint64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.pos += uint64(n)
+
+	return n, err
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,132 @@
+package archive
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+type Zip struct {
+	data   io.ReaderAt
+	reader *zip.Reader
+}
+
+func NewZip(data io.ReaderAt, size int64) (Reader, error) {
+	reader, err := zip.NewReader(data, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Zip{data: data, reader: reader}, nil
+}
+
+func (z *Zip) Walk(handler ItemHandler) error {
+	for _, file := range z.reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		loc, err := ZipLocator(file)
+		if err != nil {
+			return fmt.Errorf("zip %s error: %w", file.Name, err)
+		}
+
+		reader, err := ExtractZip(z.data, loc)
+		if err != nil {
+			return fmt.Errorf("zip %s error: %w", file.Name, err)
+		}
+
+		if err := handler(Item{Name: file.Name, Locator: loc}, reader); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (z *Zip) Extract(loc Locator) (io.ReadCloser, error) {
+	return ExtractZip(z.data, loc)
+}
+
+// ZipLocator returns locator of zip item, sizes and offsets are taken from zip64 extra fields when they are present.
+func ZipLocator(file *zip.File) (Locator, error) {
+	offset, err := file.DataOffset()
+	if err != nil {
+		return Locator{}, fmt.Errorf("offset error: %w", err)
+	}
+
+	return Locator{
+		Offset:         uint64(offset),
+		Size:           file.UncompressedSize64,
+		SizeCompressed: file.CompressedSize64,
+		Method:         file.Method,
+		CRC32:          file.CRC32,
+	}, nil
+}
+
+// ZipMethod returns zip compression method of the item.
+func (l Locator) ZipMethod() uint16 {
+	if l.Method == zip.Store && l.Size != l.SizeCompressed {
+		// items indexed before compression methods were stored are deflated ones
+		return zip.Deflate
+	}
+
+	return l.Method
+}
+
+// ExtractZip returns reader of zip item data, which verifies item checksum at the end of the data.
+func ExtractZip(data io.ReaderAt, loc Locator) (io.ReadCloser, error) {
+	section := io.NewSectionReader(data, int64(loc.Offset), int64(loc.SizeCompressed))
+
+	var reader io.ReadCloser
+
+	switch loc.ZipMethod() {
+	case zip.Store:
+		reader = io.NopCloser(section)
+	case zip.Deflate:
+		reader = flate.NewReader(section)
+	default:
+		return nil, fmt.Errorf("%w: zip compression method %d", ErrUnsupported, loc.Method)
+	}
+
+	// there is no checksum for items indexed before checksums were stored
+	if loc.CRC32 == 0 {
+		return reader, nil
+	}
+
+	return &checksumReader{reader: reader, hash: crc32.NewIEEE(), crc: loc.CRC32, size: loc.Size}, nil
+}
+
+type checksumReader struct {
+	reader io.ReadCloser
+	hash   hash.Hash32
+	crc    uint32
+	size   uint64
+	read   uint64
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.hash.Write(p[:n])
+	r.read += uint64(n)
+
+	if errors.Is(err, io.EOF) {
+		if r.read != r.size {
+			return n, io.ErrUnexpectedEOF
+		}
+
+		if r.hash.Sum32() != r.crc {
+			return n, ErrChecksum
+		}
+	}
+
+	return n, err
+}
+
+func (r *checksumReader) Close() error {
+	return r.reader.Close()
+}
@@ -0,0 +1,47 @@
+package echoext
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/egnd/fb2lib/pkg/metrics"
+	"github.com/labstack/echo/v4"
+)
+
+// NewMetricsMiddleware counts requests and measures their latencies per route.
+func NewMetricsMiddleware(reg *metrics.Registry, namespace string) echo.MiddlewareFunc {
+	requests := reg.Counter(namespace+"_http_requests_total", "Count of http requests.", "method", "route", "status")
+	latency := reg.Histogram(namespace+"_http_request_duration_seconds", "Latency of http requests.", nil,
+		"method", "route",
+	)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			start := time.Now()
+			err = next(c)
+
+			// path of the request is left by the router if no route is matched
+			route := c.Path()
+			if route == "" || errors.Is(err, echo.ErrNotFound) {
+				route = "unmatched"
+			}
+
+			status := c.Response().Status
+			if err != nil {
+				var httpErr *echo.HTTPError
+				if errors.As(err, &httpErr) {
+					status = httpErr.Code
+				} else if !c.Response().Committed {
+					status = http.StatusInternalServerError
+				}
+			}
+
+			requests.Inc(c.Request().Method, route, strconv.Itoa(status))
+			latency.ObserveSince(start, c.Request().Method, route)
+
+			return
+		}
+	}
+}
@@ -3,18 +3,28 @@ package echoext
 import (
 	"errors"
 	"io"
+	"sync"
 
 	pongo2 "github.com/flosch/pongo2/v5"
 	"github.com/labstack/echo/v4"
+
+	"github.com/egnd/fb2lib/pkg/metrics"
 )
 
+// CSRFField is the name of the CSRF token form field and of the template variable with it.
+const CSRFField = "csrf"
+
 type PongoRendererCfg struct {
 	Debug   bool
 	TplsDir string
+	// CacheRequests counts templates cache requests by "hit" or "miss" label value.
+	CacheRequests *metrics.Counter
 }
 
 type PongoRenderer struct {
-	set *pongo2.TemplateSet
+	set           *pongo2.TemplateSet
+	cached        sync.Map
+	cacheRequests *metrics.Counter
 }
 
 func NewPongoRenderer(
@@ -32,7 +42,8 @@ func NewPongoRenderer(
 	}
 
 	res := PongoRenderer{
-		set: pongo2.NewSet("echo_renderer", loader),
+		set:           pongo2.NewSet("echo_renderer", loader),
+		cacheRequests: cfg.CacheRequests,
 	}
 
 	res.set.Debug = cfg.Debug
@@ -49,6 +60,15 @@ func (r *PongoRenderer) Render(w io.Writer, name string, data interface{}, ctx e
 		return err
 	}
 
+	if r.cacheRequests != nil {
+		// templates are compiled every time at debug mode
+		if _, ok := r.cached.LoadOrStore(name, true); ok && !r.set.Debug {
+			r.cacheRequests.Inc("hit")
+		} else {
+			r.cacheRequests.Inc("miss")
+		}
+	}
+
 	var pongoCtx pongo2.Context
 	if data != nil {
 		var ok bool
@@ -57,5 +77,16 @@ func (r *PongoRenderer) Render(w io.Writer, name string, data interface{}, ctx e
 		}
 	}
 
+	// token of CSRF middleware is passed to templates for forms
+	if ctx != nil {
+		if token, ok := ctx.Get(CSRFField).(string); ok {
+			if pongoCtx == nil {
+				pongoCtx = pongo2.Context{}
+			}
+
+			pongoCtx[CSRFField] = token
+		}
+	}
+
 	return tpl.ExecuteWriter(pongoCtx, w)
 }
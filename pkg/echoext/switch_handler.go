@@ -0,0 +1,32 @@
+package echoext
+
+import (
+	"net/http"
+	"sync"
+)
+
+// SwitchHandler passes requests to the current handler, which could be replaced while requests are served.
+// Requests started before the replacement are finished by the previous handler.
+type SwitchHandler struct {
+	mu      sync.RWMutex
+	handler http.Handler
+}
+
+func NewSwitchHandler(handler http.Handler) *SwitchHandler {
+	return &SwitchHandler{handler: handler}
+}
+
+func (h *SwitchHandler) Set(handler http.Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.handler = handler
+}
+
+func (h *SwitchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	handler := h.handler
+	h.mu.RUnlock()
+
+	handler.ServeHTTP(w, r)
+}
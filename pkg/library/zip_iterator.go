@@ -2,11 +2,11 @@ package library
 
 import (
 	"archive/zip"
-	"compress/flate"
-	"io"
 	"os"
 
 	"github.com/rs/zerolog"
+
+	"github.com/egnd/fb2lib/pkg/archive"
 )
 
 type ZipItemReader struct {
@@ -39,23 +39,23 @@ func (z *ZipItemReader) IterateItems(handler IZipItemHandler) (err error) {
 		i++
 		logger := z.logger.With().Str("zip_item", zipFile.Name).Logger()
 
-		if zipFile.Method != zip.Deflate {
-			logger.Warn().Uint16("compression", zipFile.Method).Msg("check item compression type")
+		loc, err := archive.ZipLocator(zipFile)
+		if err != nil {
+			logger.Error().Err(err).Msg("readig zip item offset")
 			continue
 		}
 
-		offset, err := zipFile.DataOffset()
+		itemReader, err := archive.ExtractZip(archiveFile, loc)
 		if err != nil {
-			logger.Error().Err(err).Msg("readig zip item offset")
+			logger.Warn().Err(err).Uint16("compression", zipFile.Method).Msg("check item compression type")
 			continue
 		}
 
-		flateReader := flate.NewReader(io.NewSectionReader(archiveFile, offset, int64(zipFile.CompressedSize64)))
-		if err = handler(zipFile, flateReader, offset, i, logger); err != nil {
+		if err = handler(zipFile, itemReader, int64(loc.Offset), i, logger); err != nil {
 			logger.Error().Err(err).Msg("handling zip item")
 		}
 
-		flateReader.Close()
+		itemReader.Close()
 	}
 
 	return
@@ -0,0 +1,342 @@
+// Package metrics collects counters, gauges and histograms and writes them in the Prometheus text format, so they
+// could be scraped from the http handler or read from the file by the node exporter textfile collector.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefBuckets are histogram buckets for durations in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is registry for metrics of the app.
+var Default = NewRegistry()
+
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+	hooks    []func()
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// OnCollect adds the hook, which is called before metrics are written, e.g. to update gauges.
+func (r *Registry) OnCollect(hook func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hooks = append(r.hooks, hook)
+}
+
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, "counter", nil, labels)}
+}
+
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(name, help, "gauge", nil, labels)}
+}
+
+// Histogram registers histogram with the upper bounds of buckets, DefBuckets are used if buckets are empty.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Histogram{r.register(name, help, "histogram", buckets, labels)}
+}
+
+func (r *Registry) register(name, help, kind string, buckets []float64, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.families[name]; ok {
+		panic(fmt.Sprintf("metric %s is registered already", name))
+	}
+
+	res := &family{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  map[string]*series{},
+	}
+	r.families[name] = res
+
+	return res
+}
+
+// WriteTo writes metrics in the Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	hooks := append([]func(){}, r.hooks...)
+	families := make([]*family, 0, len(r.families))
+	for _, item := range r.families {
+		families = append(families, item)
+	}
+	r.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	out := &countingWriter{w: bufio.NewWriter(w)}
+	for _, item := range families {
+		item.write(out)
+	}
+
+	if out.err == nil {
+		out.err = out.w.Flush()
+	}
+
+	return out.cnt, out.err
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w) //nolint:errcheck
+}
+
+// WriteFile replaces the file with metrics atomically, so the textfile collector never reads partial file.
+func (r *Registry) WriteFile(filePath string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err = r.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filePath)
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.Counter(name, help, labels...)
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return Default.Gauge(name, help, labels...)
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.Histogram(name, help, buckets, labels...)
+}
+
+type Counter struct {
+	*family
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter, negative values are ignored.
+func (c *Counter) Add(val float64, labelValues ...string) {
+	if val < 0 {
+		return
+	}
+
+	c.update(labelValues, func(item *series) { item.value += val })
+}
+
+type Gauge struct {
+	*family
+}
+
+func (g *Gauge) Set(val float64, labelValues ...string) {
+	g.update(labelValues, func(item *series) { item.value = val })
+}
+
+func (g *Gauge) Add(val float64, labelValues ...string) {
+	g.update(labelValues, func(item *series) { item.value += val })
+}
+
+type Histogram struct {
+	*family
+}
+
+func (h *Histogram) Observe(val float64, labelValues ...string) {
+	h.update(labelValues, func(item *series) {
+		if item.counts == nil {
+			item.counts = make([]uint64, len(h.buckets))
+		}
+
+		for k, bound := range h.buckets {
+			if val <= bound {
+				item.counts[k]++
+			}
+		}
+
+		item.value += val
+		item.cnt++
+	})
+}
+
+// ObserveSince observes seconds passed since the start.
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	cnt         uint64
+	counts      []uint64
+}
+
+type family struct {
+	mu      sync.Mutex
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+func (f *family) update(labelValues []string, fn func(*series)) {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s requires %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	item, ok := f.series[key]
+	if !ok {
+		item = &series{labelValues: append([]string(nil), labelValues...)}
+		f.series[key] = item
+	}
+
+	fn(item)
+}
+
+func (f *family) write(out *countingWriter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	out.printf("# HELP %s %s\n", f.name, escapeHelp(f.help))
+	out.printf("# TYPE %s %s\n", f.name, f.kind)
+
+	for _, key := range keys {
+		item := f.series[key]
+
+		if f.kind != "histogram" {
+			out.printf("%s%s %s\n", f.name, f.labelsStr(item.labelValues, ""), formatFloat(item.value))
+			continue
+		}
+
+		for k, bound := range f.buckets {
+			var cnt uint64
+			if item.counts != nil {
+				cnt = item.counts[k]
+			}
+
+			out.printf("%s_bucket%s %d\n", f.name, f.labelsStr(item.labelValues, formatFloat(bound)), cnt)
+		}
+
+		out.printf("%s_bucket%s %d\n", f.name, f.labelsStr(item.labelValues, "+Inf"), item.cnt)
+		out.printf("%s_sum%s %s\n", f.name, f.labelsStr(item.labelValues, ""), formatFloat(item.value))
+		out.printf("%s_count%s %d\n", f.name, f.labelsStr(item.labelValues, ""), item.cnt)
+	}
+}
+
+// labelsStr formats labels of the series, le label is added for histogram buckets.
+func (f *family) labelsStr(values []string, le string) string {
+	pairs := make([]string, 0, len(values)+1)
+
+	for k, name := range f.labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escapeLabel(values[k])))
+	}
+
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf(`le="%s"`, le))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(val float64) string {
+	switch {
+	case math.IsInf(val, 1):
+		return "+Inf"
+	case math.IsInf(val, -1):
+		return "-Inf"
+	case math.IsNaN(val):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	}
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(val string) string {
+	return helpReplacer.Replace(val)
+}
+
+func escapeLabel(val string) string {
+	return labelReplacer.Replace(val)
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	cnt int64
+	err error
+}
+
+func (w *countingWriter) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.cnt += int64(n)
+	w.err = err
+}